@@ -0,0 +1,118 @@
+//go:build !purego
+// +build !purego
+
+package randmap
+
+import (
+	"encoding/binary"
+	"reflect"
+
+	crand "crypto/rand"
+
+	safe "github.com/lukechampine/randmap/safe"
+)
+
+// A bufferedReader is an indexer that amortizes the cost of calling read by
+// reading random words in bulk.
+type bufferedReader struct {
+	read randReader
+	buf  [1024]byte
+	off  int // start of unread bytes in buf
+}
+
+func (b *bufferedReader) index(n uintptr) uintptr {
+	for {
+		if b.off == len(b.buf) {
+			b.read(b.buf[:])
+			b.off = 0
+		}
+		x := binary.LittleEndian.Uint64(b.buf[b.off:])
+		b.off += 8
+		if r, ok := reduce64(x, n); ok {
+			return r
+		}
+	}
+}
+
+func newBufferedReader(read randReader) *bufferedReader {
+	b := &bufferedReader{read: read}
+	b.off = len(b.buf) // start with an empty buffer
+	return b
+}
+
+// randKeysWithReplacement calls fn on n random entries of m, stopping early
+// if fn returns false. The map is decoded once, and its slot space is only
+// recomputed if the map's layout changes.
+func randKeysWithReplacement(m interface{}, n int, read randReader, fn func(it *hiter) bool) {
+	if n <= 0 {
+		return
+	}
+	s := newSampler(m, newBufferedReader(read), false)
+	var it hiter
+	for i := 0; i < n; i++ {
+		if s.entry(&it); !fn(&it) {
+			return
+		}
+	}
+}
+
+func appendKeys(dst []interface{}, m interface{}, n int, read randReader) []interface{} {
+	kt := reflect.TypeOf(m).Key()
+	randKeysWithReplacement(m, n, read, func(it *hiter) bool {
+		dst = append(dst, reflect.NewAt(kt, it.key).Elem().Interface())
+		return true
+	})
+	return dst
+}
+
+func streamKeys(m interface{}, n int, read randReader, fn func(k interface{}) bool) {
+	kt := reflect.TypeOf(m).Key()
+	randKeysWithReplacement(m, n, read, func(it *hiter) bool {
+		return fn(reflect.NewAt(kt, it.key).Elem().Interface())
+	})
+}
+
+// KeysWithReplacement appends n uniform random keys of m, which must be a
+// non-empty map, to dst and returns the extended slice. The same key may be
+// selected more than once. It is equivalent to calling Key n times, but much
+// faster, since the map is only decoded once and random bytes are read in
+// bulk.
+func KeysWithReplacement(m interface{}, n int, dst []interface{}) []interface{} {
+	if probeErr != nil {
+		return safe.KeysWithReplacement(m, n, dst)
+	}
+	return appendKeys(dst, m, n, crand.Read)
+}
+
+// KeysWithReplacementFunc calls fn on n uniform random keys of m, which must
+// be a non-empty map, stopping early if fn returns false. The same key may be
+// selected more than once. Unlike KeysWithReplacement, it uses constant
+// memory, so n may be arbitrarily large.
+func KeysWithReplacementFunc(m interface{}, n int, fn func(k interface{}) bool) {
+	if probeErr != nil {
+		safe.KeysWithReplacementFunc(m, n, fn)
+		return
+	}
+	streamKeys(m, n, crand.Read, fn)
+}
+
+// FastKeysWithReplacement appends n pseudorandom keys of m, which must be a
+// non-empty map, to dst and returns the extended slice. The same key may be
+// selected more than once.
+func FastKeysWithReplacement(m interface{}, n int, dst []interface{}) []interface{} {
+	if probeErr != nil {
+		return safe.FastKeysWithReplacement(m, n, dst)
+	}
+	return appendKeys(dst, m, n, fastRead)
+}
+
+// FastKeysWithReplacementFunc calls fn on n pseudorandom keys of m, which
+// must be a non-empty map, stopping early if fn returns false. The same key
+// may be selected more than once.
+func FastKeysWithReplacementFunc(m interface{}, n int, fn func(k interface{}) bool) {
+	if probeErr != nil {
+		safe.FastKeysWithReplacementFunc(m, n, fn)
+		return
+	}
+	streamKeys(m, n, fastRead, fn)
+}
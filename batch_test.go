@@ -0,0 +1,83 @@
+package randmap
+
+import "testing"
+
+func TestKeysWithReplacement(t *testing.T) {
+	m := make(map[int]int)
+	for i := 0; i < 100; i++ {
+		m[i] = i
+	}
+	dst := []interface{}{"prefix"}
+	dst = KeysWithReplacement(m, 1000, dst)
+	if len(dst) != 1001 || dst[0] != "prefix" {
+		t.Fatal("KeysWithReplacement did not append to dst")
+	}
+
+	keys := FastKeysWithReplacement(m, 100*len(m), nil)
+	counts := make([]int, len(m))
+	for _, k := range keys {
+		counts[k.(int)]++
+	}
+	checkUniform(t, "FastKeysWithReplacement", counts)
+
+	var n int
+	FastKeysWithReplacementFunc(m, 1<<40, func(k interface{}) bool {
+		if _, ok := m[k.(int)]; !ok {
+			t.Fatal("bad key:", k)
+		}
+		n++
+		return n < 500
+	})
+	if n != 500 {
+		t.Fatal("expected early stop after 500 keys, got", n)
+	}
+
+	if keys := KeysWithReplacement(make(map[int]int), 0, nil); len(keys) != 0 {
+		t.Fatal("expected no keys, got", keys)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic when sampling from empty map")
+		}
+	}()
+	KeysWithReplacement(make(map[int]int), 1, nil)
+}
+
+func BenchmarkKeysWithReplacement(b *testing.B) {
+	m := make(map[int]int, 10000)
+	for i := 0; i < 10000; i++ {
+		m[i] = i
+	}
+	const n = 1000
+	dst := make([]interface{}, 0, n)
+
+	b.Run("key", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			dst = dst[:0]
+			for j := 0; j < n; j++ {
+				dst = append(dst, Key(m))
+			}
+		}
+	})
+
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			dst = KeysWithReplacement(m, n, dst[:0])
+		}
+	})
+
+	b.Run("fastkey", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			dst = dst[:0]
+			for j := 0; j < n; j++ {
+				dst = append(dst, FastKey(m))
+			}
+		}
+	})
+
+	b.Run("fastbatch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			dst = FastKeysWithReplacement(m, n, dst[:0])
+		}
+	})
+}
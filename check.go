@@ -0,0 +1,69 @@
+//go:build !purego
+// +build !purego
+
+package randmap
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+)
+
+// checkMode enables a debugging mode in which every entry read from a map is
+// verified against an ordinary reflect lookup. It is enabled by setting the
+// RANDMAP_CHECK environment variable, or by building with the randmap_check
+// tag. It is very slow, and intended to catch runtime layout drift in CI.
+var checkMode = os.Getenv("RANDMAP_CHECK") != ""
+
+// checkSlot panics if the key/value pair that 'it' points to is not an entry
+// of m. r is the index of the slot in s, and is used to report the slot's
+// coordinates.
+func checkSlot(m reflect.Value, it *hiter, s slotSpace, r uintptr) {
+	k := reflect.NewAt(m.Type().Key(), it.key).Elem()
+	if ki := k.Interface(); ki != ki {
+		return // NaNs can't be looked up
+	}
+	v := m.MapIndex(k)
+	if !v.IsValid() {
+		panic(fmt.Sprintf("randmap: %v: key %v is not in the map", s.coords(r), k))
+	} else if !reflect.DeepEqual(v.Interface(), reflect.NewAt(m.Type().Elem(), it.value).Elem().Interface()) {
+		panic(fmt.Sprintf("randmap: %v: wrong value for key %v", s.coords(r), k))
+	}
+}
+
+// iterCheck tracks the keys yielded by an Iterator in check mode.
+type iterCheck struct {
+	m    reflect.Value
+	n    int
+	seen map[interface{}]struct{}
+}
+
+func newIterCheck(m reflect.Value) *iterCheck {
+	return &iterCheck{
+		m:    m,
+		n:    m.Len(),
+		seen: make(map[interface{}]struct{}, m.Len()),
+	}
+}
+
+// visit checks the key/value pair that 'it' points to, and that its key has
+// not been yielded before.
+func (c *iterCheck) visit(it *hiter, s slotSpace, r uintptr) {
+	checkSlot(c.m, it, s, r)
+	k := reflect.NewAt(c.m.Type().Key(), it.key).Elem().Interface()
+	if k != k {
+		c.n-- // NaNs are distinct, so there's nothing to check
+		return
+	}
+	if _, ok := c.seen[k]; ok {
+		panic(fmt.Sprintf("randmap: %v: key %v yielded twice", s.coords(r), k))
+	}
+	c.seen[k] = struct{}{}
+}
+
+// done checks that every key of the map was yielded.
+func (c *iterCheck) done() {
+	if len(c.seen) != c.n {
+		panic(fmt.Sprintf("randmap: iterator yielded %v distinct keys, but map has %v", len(c.seen), c.n))
+	}
+}
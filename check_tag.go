@@ -0,0 +1,6 @@
+//go:build randmap_check && !purego
+// +build randmap_check,!purego
+
+package randmap
+
+func init() { checkMode = true }
@@ -0,0 +1,45 @@
+//go:build !purego
+// +build !purego
+
+package randmap
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestCheckMode(t *testing.T) {
+	defer func(b bool) { checkMode = b }(checkMode)
+	checkMode = true
+
+	m := make(map[int]int)
+	for i := 0; i < 1000; i++ {
+		m[i] = i
+	}
+	for i := 0; i < 1000; i++ {
+		FastKey(m)
+		FastVal(m)
+	}
+	var k, v int
+	for it := FastIter(m, &k, &v); it.Next(); {
+	}
+}
+
+func TestCheckSlot(t *testing.T) {
+	var m interface{} = map[int]int{0: 0, 1: 1}
+	ei := (*emptyInterface)(unsafe.Pointer(&m))
+	s := newSlotSpace((*maptype)(ei.typ), (*hmap)(ei.val))
+
+	badKey, val := 2, 0
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("expected checkSlot to panic on a key that is not in the map")
+		} else if msg, _ := r.(string); !strings.Contains(msg, s.coords(3)) {
+			t.Fatalf("expected panic to report slot coordinates, got %q", msg)
+		}
+	}()
+	checkSlot(reflect.ValueOf(m), &hiter{key: unsafe.Pointer(&badKey), value: unsafe.Pointer(&val)}, s, 3)
+}
@@ -0,0 +1,157 @@
+//go:build !purego
+// +build !purego
+
+package randmap
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+
+	"github.com/lukechampine/randmap/perm"
+)
+
+// ErrResized is returned when resuming an Iterator from a checkpoint taken
+// from a different map, from the same map before it was resized, or in a
+// different process. Checkpoints only remain valid within a single process.
+var ErrResized = errors.New("randmap: checkpoint does not match the map; it was resized, is a different map, or was taken by another process")
+
+// errTolerant is returned when checkpointing a tolerant Iterator, or one that
+// has deleted entries, since the checkpoint would not record which entries
+// were already yielded.
+var errTolerant = errors.New("randmap: Iterators that tolerate modification cannot be checkpointed")
+
+// fingerprint hashes a sequence of integers.
+func fingerprint(xs ...uint64) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, x := range xs {
+		binary.LittleEndian.PutUint64(buf[:], x)
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+// An Iterator checkpoint consists of a version byte, a generator kind byte,
+// the stamp of the map, the number of entries visited, the size of the slot
+// space, and the encoded generator state. The stamp of a bucket-based map
+// doesn't always change when its overflow chains lengthen, so the slot space
+// is recorded as well.
+const (
+	checkpointVersion = 3
+	checkpointHeader  = 26
+
+	genDone    = 0 // the iterator is exhausted; no stamp or state follows
+	genFeistel = 1
+	genShuffle = 2
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler. It encodes the position
+// of the Iterator within its permutation, so that an Iterator for the same
+// map can later resume where this one left off; see UnmarshalBinary. The
+// encoding includes the permutation's key, so if the permutation must be
+// unpredictable, the encoding must be kept secret. Iterators created by
+// TolerantIter, and Iterators that have deleted entries, cannot be
+// checkpointed.
+func (i *Iterator) MarshalBinary() ([]byte, error) {
+	if i != nil && i.fallback != nil {
+		return i.fallback.MarshalBinary()
+	}
+	if i == nil {
+		return []byte{checkpointVersion, genDone}, nil
+	} else if i.si.tracking {
+		return nil, errTolerant
+	}
+	kind := byte(genFeistel)
+	if _, ok := i.si.gen.(*shuffleGenerator); ok {
+		kind = genShuffle
+	}
+	state, err := i.si.gen.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, checkpointHeader, checkpointHeader+len(state))
+	b[0], b[1] = checkpointVersion, kind
+	binary.LittleEndian.PutUint64(b[2:], i.si.h.stamp())
+	binary.LittleEndian.PutUint64(b[10:], uint64(i.si.visited))
+	binary.LittleEndian.PutUint64(b[18:], uint64(i.si.space.size()))
+	return append(b, state...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It restores a
+// checkpoint produced by MarshalBinary, after which the Iterator continues
+// the checkpointed permutation: entries that were already visited are not
+// visited again, and no entry is skipped. The Iterator must have been created
+// for the same map as the checkpointed Iterator, e.g.:
+//
+//	i := randmap.Iter(m, &k, &v)
+//	if err := i.UnmarshalBinary(checkpoint); err != nil {
+//		// handle err
+//	}
+//	for i.Next() {
+//		// use k and v
+//	}
+//
+// Since slots are assigned using a hash seed that is chosen randomly for
+// each map, a checkpoint is only valid for the map it was taken from, and
+// only until that map is resized. Otherwise, UnmarshalBinary returns
+// ErrResized. In particular, a checkpoint cannot be resumed in another
+// process, even if the map is rebuilt with the same contents.
+func (i *Iterator) UnmarshalBinary(b []byte) error {
+	if i != nil && i.fallback != nil {
+		return i.fallback.UnmarshalBinary(b)
+	} else if i != nil && i.si.tracking {
+		return errTolerant
+	}
+	if len(b) < 2 || b[0] != checkpointVersion {
+		return errors.New("randmap: invalid iterator checkpoint")
+	}
+	if b[1] == genDone {
+		// the checkpointed map was empty
+		if i != nil && i.si.h.length() != 0 {
+			return ErrResized
+		} else if i != nil {
+			// the map has since been emptied; rebuild the generator, so that
+			// Reset and Reseed work as usual
+			i.si.permute()
+			i.si.visited = i.si.h.length()
+			i.si.check = nil
+			i.cur = false
+		}
+		return nil
+	} else if len(b) < checkpointHeader {
+		return errors.New("randmap: invalid iterator checkpoint")
+	} else if i == nil || binary.LittleEndian.Uint64(b[2:]) != i.si.h.stamp() {
+		return ErrResized
+	}
+	space := newSlotSpace(i.si.t, i.si.h)
+	if binary.LittleEndian.Uint64(b[18:]) != uint64(space.size()) {
+		return ErrResized
+	}
+
+	var gen interface {
+		generator
+		encoding.BinaryUnmarshaler
+	}
+	switch b[1] {
+	case genFeistel:
+		gen = perm.NewGenerator(0, 0)
+	case genShuffle:
+		gen = new(shuffleGenerator)
+	default:
+		return errors.New("randmap: invalid iterator checkpoint")
+	}
+	if err := gen.UnmarshalBinary(b[checkpointHeader:]); err != nil {
+		return err
+	}
+	i.si.gen = gen
+	i.si.space = space
+	i.si.visited = int(binary.LittleEndian.Uint64(b[10:]))
+	i.cur = false
+	i.si.snapshot()
+	// the checkpoint doesn't record which entries were visited before it was
+	// taken, so the iteration can't be checked for completeness
+	i.si.check = nil
+	return nil
+}
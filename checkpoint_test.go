@@ -0,0 +1,173 @@
+//go:build !purego
+// +build !purego
+
+package randmap
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/lukechampine/randmap/internal/randtest"
+)
+
+func TestIteratorCheckpoint(t *testing.T) {
+	for _, n := range iterSizes {
+		m := randtest.IntMap(n)
+		var k, v int
+		it := FastIter(m, &k, &v)
+		seen := make(map[int]bool)
+		for j := 0; j < n/2 && it.Next(); j++ {
+			seen[k] = true
+		}
+		b, err := it.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// resume in a fresh iterator; it should follow the same permutation
+		var k2, v2 int
+		it2 := Iter(m, &k2, &v2)
+		if err := it2.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		for it2.Next() {
+			if !it.Next() || k != k2 {
+				t.Fatal("resumed iterator diverged from original")
+			} else if seen[k2] {
+				t.Fatalf("resumed iterator revisited %v", k2)
+			}
+			seen[k2] = true
+		}
+		if it.Next() {
+			t.Fatal("resumed iterator stopped early")
+		} else if len(seen) != n {
+			t.Fatalf("visited %v of %v entries", len(seen), n)
+		}
+
+		// an exhausted iterator stays exhausted
+		b, _ = it2.MarshalBinary()
+		it3 := FastIter(m, &k, &v)
+		if err := it3.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		} else if it3.Next() {
+			t.Fatal("resumed exhausted iterator returned an entry")
+		}
+
+		// a checkpoint of a different slot space is rejected, even if the
+		// stamp matches
+		b, _ = FastIter(m, &k, &v).MarshalBinary()
+		b[18]++
+		if err := FastIter(m, &k, &v).UnmarshalBinary(b); err != ErrResized {
+			t.Fatal("expected ErrResized for a different slot space, got", err)
+		}
+
+		// a copy of the map has a different seed
+		cp := make(map[int]int)
+		for k, v := range m {
+			cp[k] = v
+		}
+		b, _ = FastIter(m, &k, &v).MarshalBinary()
+		if err := FastIter(cp, &k, &v).UnmarshalBinary(b); err != ErrResized {
+			t.Fatal("expected ErrResized for a different map, got", err)
+		}
+
+		// resize the map
+		for i := n; i < 4*n; i++ {
+			m[i] = i
+		}
+		if err := FastIter(m, &k, &v).UnmarshalBinary(b); err != ErrResized {
+			t.Fatal("expected ErrResized after growing the map, got", err)
+		}
+	}
+
+	// an empty map has a nil iterator, which is always exhausted; its
+	// checkpoint doesn't match a non-empty map
+	var k, v int
+	b, err := FastIter(map[int]int{}, &k, &v).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	} else if err := FastIter(map[int]int{}, &k, &v).UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	it := FastIter(map[int]int{1: 1}, &k, &v)
+	if err := it.UnmarshalBinary(b); err != ErrResized {
+		t.Fatal("expected ErrResized for a non-empty map, got", err)
+	} else if !it.Next() || k != 1 {
+		t.Fatal("failed restore modified the iterator")
+	}
+
+	// but it does match a map that has since been emptied
+	m := map[int]int{1: 1}
+	it = FastIter(m, &k, &v)
+	delete(m, 1)
+	if err := it.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	} else if it.Next() || it.Remaining() != 0 {
+		t.Fatal("resumed exhausted iterator returned an entry")
+	}
+	it.Reset()
+	if it.Next() || it.Remaining() != 0 {
+		t.Fatal("reset exhausted iterator returned an entry")
+	}
+	m[2] = 2
+	it.Reseed()
+	if !it.Next() || k != 2 {
+		t.Fatal("reseeded iterator did not visit new entry")
+	}
+	for _, b := range [][]byte{nil, {0}, {checkpointVersion, genFeistel}, append([]byte{checkpointVersion, 7}, make([]byte, checkpointHeader-2)...)} {
+		if err := it.UnmarshalBinary(b); err == nil {
+			t.Errorf("expected error for invalid checkpoint %v", b)
+		}
+	}
+}
+
+func TestIteratorCheckpointDelete(t *testing.T) {
+	for _, n := range iterSizes {
+		m := randtest.IntMap(n)
+		var k, v int
+		it := FastIter(m, &k, &v)
+		seen := make(map[int]bool)
+		for j := 0; j < n/2 && it.Next(); j++ {
+			seen[k] = true
+		}
+		b, err := it.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The first Delete replays the permutation to find the entries that
+		// were already yielded, including those yielded before the
+		// checkpoint. It must also leave the iterator where it was.
+		it = FastIter(m, &k, &v)
+		if err := it.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		it.Next()
+		seen[k] = true
+		hashes := make(map[uint64]bool)
+		for k := range seen {
+			k := k
+			hashes[it.si.keyHash(unsafe.Pointer(&k))] = true
+		}
+		it.Delete()
+		if len(it.si.yielded) != len(seen) {
+			t.Fatalf("%v: expected %v yielded entries, got %v", n, len(seen), len(it.si.yielded))
+		}
+		for _, x := range it.si.yielded {
+			if !hashes[x] {
+				t.Fatalf("%v: replay found an entry that was not yielded", n)
+			}
+		}
+		for it.Next() {
+			if seen[k] {
+				t.Fatalf("%v: key %v yielded twice", n, k)
+			}
+			seen[k] = true
+		}
+		if len(seen) != n {
+			t.Fatalf("%v: visited %v of %v entries", n, len(seen), n)
+		} else if _, err := it.MarshalBinary(); err == nil {
+			t.Fatalf("%v: expected Iterator not to be checkpointed after Delete", n)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !go1.22 && !purego
+// +build !go1.22,!purego
+
+package randmap
+
+import (
+	"encoding/binary"
+	"sync"
+
+	crand "crypto/rand"
+	mrand "math/rand"
+)
+
+// fastPool holds independently-seeded generators, so that concurrent callers
+// of fastRead do not contend on the global math/rand lock.
+var fastPool = sync.Pool{
+	New: func() interface{} {
+		var seed [8]byte
+		crand.Read(seed[:])
+		return mrand.New(mrand.NewSource(int64(binary.LittleEndian.Uint64(seed[:]))))
+	},
+}
+
+// fastRead fills p with pseudorandom bytes. It is safe for concurrent use.
+func fastRead(p []byte) (int, error) {
+	r := fastPool.Get().(*mrand.Rand)
+	n, err := r.Read(p)
+	fastPool.Put(r)
+	return n, err
+}
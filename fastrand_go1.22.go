@@ -0,0 +1,15 @@
+//go:build go1.22 && !purego
+// +build go1.22,!purego
+
+package randmap
+
+import "math/rand/v2"
+
+// runtimeSource is a rand.Source backed by the runtime's per-thread ChaCha8
+// generator, which the top-level math/rand/v2 functions use without locking.
+type runtimeSource struct{}
+
+func (runtimeSource) Uint64() uint64 { return rand.Uint64() }
+
+// fastRead fills p with pseudorandom bytes. It is safe for concurrent use.
+func fastRead(p []byte) (int, error) { return sourceReader{runtimeSource{}}.Read(p) }
@@ -0,0 +1,183 @@
+//go:build go1.18 && !purego
+// +build go1.18,!purego
+
+package randmap
+
+import (
+	crand "crypto/rand"
+
+	safe "github.com/lukechampine/randmap/safe"
+)
+
+// The functions in this file are type-safe equivalents of Key, Val, Iter, and
+// Pop. Since they know the key and value types statically, they can copy
+// entries directly out of the map, without allocating an interface{} or
+// calling into reflect.
+
+func randKeyOf[K comparable, V any](m map[K]V, read randReader) K {
+	it := randEntry(m, read)
+	return *(*K)(it.key)
+}
+
+func randValOf[K comparable, V any](m map[K]V, read randReader) V {
+	it := randEntry(m, read)
+	return *(*V)(it.value)
+}
+
+func randEntryOf[K comparable, V any](m map[K]V, read randReader) (K, V) {
+	it := randEntry(m, read)
+	return *(*K)(it.key), *(*V)(it.value)
+}
+
+func randPopEntry[K comparable, V any](m map[K]V, read randReader) (K, V) {
+	k, v := randEntryOf(m, read)
+	delete(m, k)
+	return k, v
+}
+
+// A TypedIterator iterates over a map in random or pseudorandom order. It is
+// intended to be used in a for loop like so:
+//
+//	m := make(map[int]int)
+//	i := IterOf(m)
+//	for i.Next() {
+//	    // use i.Key() and i.Val()
+//	}
+type TypedIterator[K comparable, V any] struct {
+	si *slotIter
+	k  K
+	v  V
+
+	// used instead of the above if the runtime probe failed
+	fallback *safe.TypedIterator[K, V]
+}
+
+// Next advances the TypedIterator to the next element in the map. It returns
+// false when all of the elements have been enumerated. Like Iterator.Next, it
+// panics if the map has been modified during iteration.
+func (i *TypedIterator[K, V]) Next() bool {
+	if i == nil {
+		return false
+	} else if i.fallback != nil {
+		return i.fallback.Next()
+	}
+	if !i.si.next() {
+		return false
+	}
+	i.k, i.v = *(*K)(i.si.it.key), *(*V)(i.si.it.value)
+	return true
+}
+
+// Key returns the key of the current element.
+func (i *TypedIterator[K, V]) Key() K {
+	if i.fallback != nil {
+		return i.fallback.Key()
+	}
+	return i.k
+}
+
+// Val returns the value of the current element.
+func (i *TypedIterator[K, V]) Val() V {
+	if i.fallback != nil {
+		return i.fallback.Val()
+	}
+	return i.v
+}
+
+func randIterOf[K comparable, V any](m map[K]V, read randReader) *TypedIterator[K, V] {
+	si := newSlotIter(m, read)
+	if si == nil {
+		return nil
+	}
+	return &TypedIterator[K, V]{si: si}
+}
+
+// KeyOf returns a uniform random key of m, which must be a non-empty map.
+func KeyOf[K comparable, V any](m map[K]V) K {
+	if probeErr != nil {
+		return safe.KeyOf(m)
+	}
+	return randKeyOf(m, crand.Read)
+}
+
+// ValOf returns a uniform random value of m, which must be a non-empty map.
+func ValOf[K comparable, V any](m map[K]V) V {
+	if probeErr != nil {
+		return safe.ValOf(m)
+	}
+	return randValOf(m, crand.Read)
+}
+
+// EntryOf returns a uniform random key of m, which must be a non-empty map,
+// along with its associated value.
+func EntryOf[K comparable, V any](m map[K]V) (K, V) {
+	if probeErr != nil {
+		return safe.EntryOf(m)
+	}
+	return randEntryOf(m, crand.Read)
+}
+
+// PopEntry removes a uniform random entry from m, which must be a non-empty
+// map, and returns its key and value. It is the type-safe equivalent of Pop.
+// As with the builtin delete, an entry whose key is not equal to itself (such
+// as NaN) is returned but not removed.
+func PopEntry[K comparable, V any](m map[K]V) (K, V) {
+	if probeErr != nil {
+		return safe.PopEntry(m)
+	}
+	return randPopEntry(m, crand.Read)
+}
+
+// IterOf returns a random iterator for m. Next panics if the map gains or
+// loses entries during iteration; see Iterator.Next.
+func IterOf[K comparable, V any](m map[K]V) *TypedIterator[K, V] {
+	if probeErr != nil {
+		return &TypedIterator[K, V]{fallback: safe.IterOf(m)}
+	}
+	return randIterOf(m, crand.Read)
+}
+
+// FastKeyOf returns a pseudorandom key of m, which must be a non-empty map.
+func FastKeyOf[K comparable, V any](m map[K]V) K {
+	if probeErr != nil {
+		return safe.FastKeyOf(m)
+	}
+	return randKeyOf(m, fastRead)
+}
+
+// FastValOf returns a pseudorandom value of m, which must be a non-empty map.
+func FastValOf[K comparable, V any](m map[K]V) V {
+	if probeErr != nil {
+		return safe.FastValOf(m)
+	}
+	return randValOf(m, fastRead)
+}
+
+// FastEntryOf returns a pseudorandom key of m, which must be a non-empty map,
+// along with its associated value.
+func FastEntryOf[K comparable, V any](m map[K]V) (K, V) {
+	if probeErr != nil {
+		return safe.FastEntryOf(m)
+	}
+	return randEntryOf(m, fastRead)
+}
+
+// FastPopEntry removes a pseudorandom entry from m, which must be a non-empty
+// map, and returns its key and value. It is the type-safe equivalent of
+// FastPop. As with the builtin delete, an entry whose key is not equal to
+// itself (such as NaN) is returned but not removed.
+func FastPopEntry[K comparable, V any](m map[K]V) (K, V) {
+	if probeErr != nil {
+		return safe.FastPopEntry(m)
+	}
+	return randPopEntry(m, fastRead)
+}
+
+// FastIterOf returns a pseudorandom iterator for m. Next panics if the map
+// gains or loses entries during iteration; see Iterator.Next.
+func FastIterOf[K comparable, V any](m map[K]V) *TypedIterator[K, V] {
+	if probeErr != nil {
+		return &TypedIterator[K, V]{fallback: safe.FastIterOf(m)}
+	}
+	return randIterOf(m, fastRead)
+}
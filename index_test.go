@@ -0,0 +1,38 @@
+//go:build !purego
+// +build !purego
+
+package randmap
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestRandIndexBias checks randIndex and reduce32 against a bound of about
+// two thirds of the word size. Reducing modulo such a bound would select
+// values in its lower half about twice as often as values in its upper half.
+func TestRandIndexBias(t *testing.T) {
+	const iters = 30000
+	n := ^uintptr(0) / 3 * 2
+	counts := make([]int, 3)
+	for i := 0; i < iters; i++ {
+		counts[randIndex(rand.Read, n)/(n/3+1)]++
+	}
+	checkUniform(t, "randIndex", counts)
+
+	n32 := ^uint32(0) / 3 * 2
+	counts = make([]int, 3)
+	for i := 0; i < iters; i++ {
+		counts[reduce32(rand.Uint32(), n32, rand.Read)/(n32/3+1)]++
+	}
+	checkUniform(t, "reduce32", counts)
+
+	// small bounds must not be broken either
+	for _, n := range []uintptr{1, 2, 3, 10} {
+		counts = make([]int, n)
+		for i := 0; i < iters; i++ {
+			counts[randIndex(rand.Read, n)]++
+		}
+		checkUniform(t, "randIndex", counts)
+	}
+}
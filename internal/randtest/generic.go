@@ -0,0 +1,107 @@
+//go:build go1.18
+// +build go1.18
+
+package randtest
+
+import "testing"
+
+// A TypedIterator is implemented by both packages' TypedIterator types,
+// instantiated for map[int]int.
+type TypedIterator interface {
+	Next() bool
+	Key() int
+	Val() int
+}
+
+// KeyOf tests that keyOf selects keys uniformly.
+func KeyOf(t *testing.T, keyOf func(map[int]int) int) {
+	t.Helper()
+	const iters = 100000
+	m := IntMap(10)
+	counts := make([]int, len(m))
+	for i := 0; i < iters; i++ {
+		counts[keyOf(m)]++
+	}
+
+	for n, c := range counts {
+		if (iters/len(m))/2 > c || c > (iters/len(m))*2 {
+			t.Errorf("suspicious count: expected %v-%v, got %v (%v)", (iters/len(m))/2, (iters/len(m))*2, c, n)
+		}
+	}
+}
+
+// EntryOf tests that entryOf and valOf select matching entries from a map
+// whose values contain pointers.
+func EntryOf(t *testing.T, entryOf func(map[string][]int) (string, []int), valOf func(map[string][]int) []int) {
+	t.Helper()
+	m := make(map[string][]int)
+	for i := 0; i < 100; i++ {
+		m[string(rune('a'+i))] = []int{i}
+	}
+	for i := 0; i < 1000; i++ {
+		k, v := entryOf(m)
+		if m[k][0] != v[0] {
+			t.Fatalf("mismatched key/value pair: %v/%v", k, v)
+		}
+		if v := valOf(m); m[string(rune('a'+v[0]))][0] != v[0] {
+			t.Fatal("bad value:", v)
+		}
+	}
+}
+
+// PopEntry tests that popEntry removes and returns matching entries, until
+// the map is empty.
+func PopEntry(t *testing.T, popEntry func(map[string][]int) (string, []int)) {
+	t.Helper()
+	m := make(map[string][]int)
+	for i := 0; i < 100; i++ {
+		m[string(rune('a'+i))] = []int{i}
+	}
+	for len(m) > 0 {
+		n := len(m)
+		k, v := popEntry(m)
+		if _, ok := m[k]; ok || len(m) != n-1 {
+			t.Fatalf("PopEntry did not remove %v", k)
+		} else if k != string(rune('a'+v[0])) {
+			t.Fatalf("mismatched key/value pair: %v/%v", k, v)
+		}
+	}
+	m["a"] = []int{0}
+	if k, _ := popEntry(m); k != "a" || len(m) != 0 {
+		t.Fatal("failed to pop last entry")
+	}
+}
+
+// IterOf tests that iterOf enumerates the map in uniformly random order.
+func IterOf(t *testing.T, iterOf func(map[int]int) TypedIterator) {
+	t.Helper()
+	const iters = 1000
+	m := IntMap(10)
+	counts := make([][]int, len(m))
+	for i := range counts {
+		counts[i] = make([]int, len(m))
+	}
+	for i := 0; i < iters; i++ {
+		it := iterOf(m)
+		for j := 0; it.Next(); j++ {
+			if it.Key() != it.Val() {
+				t.Fatalf("mismatched key/value pair: %v/%v", it.Key(), it.Val())
+			}
+			// key appeared at index j
+			counts[it.Key()][j]++
+		}
+	}
+
+	// each key should have appeared at each index about iters/len(m) times
+	for k, cs := range counts {
+		for i, c := range cs {
+			if (iters/len(m))/2 > c || c > (iters/len(m))*2 {
+				t.Errorf("suspicious count for key %v index %v: expected %v-%v, got %v", k, i, (iters/len(m))/2, (iters/len(m))*2, c)
+			}
+		}
+	}
+
+	if iterOf(map[int]int{}).Next() {
+		t.Fatal("iterator over empty map should be empty")
+	}
+}
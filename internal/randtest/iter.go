@@ -0,0 +1,195 @@
+// Package randtest contains tests that are shared by the randmap and
+// randmap/safe packages, which implement the same API. Each test takes the
+// functions under test as arguments.
+package randtest
+
+import (
+	"strings"
+	"testing"
+)
+
+// An Iterator is implemented by both packages' Iterator types.
+type Iterator interface {
+	Next() bool
+	Delete()
+	Reset()
+	Reseed()
+	Skip(n int) int
+	Remaining() int
+}
+
+// An IterFunc returns an Iterator for m, which stores each entry in k and v.
+type IterFunc func(m, k, v interface{}) Iterator
+
+// IntMap returns a map of n entries, mapping each of 0..n-1 to itself.
+func IntMap(n int) map[int]int {
+	m := make(map[int]int, n)
+	for i := 0; i < n; i++ {
+		m[i] = i
+	}
+	return m
+}
+
+// IterReset tests Reset, Reseed, Skip and Remaining on a map of n entries.
+func IterReset(t *testing.T, iter IterFunc, n int) {
+	t.Helper()
+	m := IntMap(n)
+	var k, v int
+	it := iter(m, &k, &v)
+	if it.Remaining() != n {
+		t.Fatalf("expected %v remaining, got %v", n, it.Remaining())
+	}
+	if s := it.Skip(10); s != 10 || it.Remaining() != n-10 {
+		t.Fatalf("expected to skip 10 with %v remaining, skipped %v with %v remaining", n-10, s, it.Remaining())
+	}
+	var order []int
+	for it.Next() {
+		order = append(order, k)
+	}
+	if len(order) != n-10 || it.Remaining() != 0 {
+		t.Fatalf("expected %v elements after skipping, got %v", n-10, len(order))
+	} else if it.Skip(1) != 0 {
+		t.Fatal("skipped past the end of the iterator")
+	}
+
+	// Reset should reproduce the same order
+	it.Reset()
+	if it.Remaining() != n {
+		t.Fatalf("expected %v remaining after Reset, got %v", n, it.Remaining())
+	}
+	it.Skip(10)
+	for _, exp := range order {
+		if !it.Next() || k != exp {
+			t.Fatal("Reset did not reproduce the same order")
+		}
+	}
+
+	// Reseed should produce a different order, and see new entries
+	m[n] = n
+	it.Reseed()
+	it.Skip(10)
+	var diff bool
+	seen := make(map[int]bool)
+	for j := 0; it.Next(); j++ {
+		diff = diff || j >= len(order) || order[j] != k
+		seen[k] = true
+	}
+	if !diff {
+		t.Fatal("Reseed reproduced the same order")
+	} else if len(seen) != n+1-10 {
+		t.Fatalf("expected %v elements after Reseed, got %v", n+1-10, len(seen))
+	}
+}
+
+// IterModified tests that Next panics if a map of n entries is modified
+// during iteration, and that Reseed recovers.
+func IterModified(t *testing.T, iter IterFunc, n int) {
+	t.Helper()
+	modify := map[string]func(m map[int]int){
+		"insert": func(m map[int]int) { m[-1] = -1 },
+		"delete": func(m map[int]int) { delete(m, 0) },
+	}
+	for name, fn := range modify {
+		m := IntMap(n)
+		var k, v int
+		it := iter(m, &k, &v)
+		it.Next()
+		fn(m)
+		func() {
+			defer func() {
+				if r, _ := recover().(string); !strings.Contains(r, "modified") {
+					t.Fatalf("%v (%v): expected Next to panic, got %q", name, n, r)
+				}
+			}()
+			it.Next()
+		}()
+
+		// after reseeding, iteration should proceed normally
+		it.Reseed()
+		var count int
+		for it.Next() {
+			count++
+		}
+		if count != len(m) {
+			t.Fatalf("%v (%v): expected %v elements after Reseed, got %v", name, n, len(m), count)
+		}
+	}
+}
+
+// IterDelete tests Delete on a map of n entries, along with Delete's misuse.
+func IterDelete(t *testing.T, iter IterFunc, n int) {
+	t.Helper()
+	m := IntMap(n)
+	var k, v int
+	it := iter(m, &k, &v)
+	seen := make(map[int]bool)
+	for it.Next() {
+		if seen[k] {
+			t.Fatalf("%v: key %v yielded twice", n, k)
+		}
+		seen[k] = true
+		if k%2 == 0 {
+			k = -1 // Delete must not depend on the caller's variable
+			it.Delete()
+		}
+		if it.Remaining() != n-len(seen) {
+			t.Fatalf("%v: expected %v remaining, got %v", n, n-len(seen), it.Remaining())
+		}
+	}
+	if len(seen) != n {
+		t.Fatalf("%v: expected %v elements, got %v", n, n, len(seen))
+	} else if len(m) != n/2 {
+		t.Fatalf("%v: expected %v elements to remain in map, got %v", n, n/2, len(m))
+	}
+	for k := range m {
+		if k%2 == 0 {
+			t.Fatalf("%v: key %v was not deleted", n, k)
+		}
+	}
+
+	// Reset should visit, and count, only the remaining elements
+	it.Reset()
+	if it.Remaining() != n/2 {
+		t.Fatalf("%v: expected %v remaining after Reset, got %v", n, n/2, it.Remaining())
+	} else if s := it.Skip(n); s != n/2 {
+		t.Fatalf("%v: expected to skip %v elements after Reset, got %v", n, n/2, s)
+	}
+	it.Reset()
+	var count int
+	for it.Next() {
+		count++
+	}
+	if count != n/2 {
+		t.Fatalf("%v: expected %v elements after Reset, got %v", n, n/2, count)
+	}
+
+	// other modifications are still detected
+	m = map[int]int{0: 0, 1: 1, 2: 2, 3: 3}
+	it = iter(m, &k, &v)
+	it.Next()
+	it.Delete()
+	m[-1] = -1
+	func() {
+		defer func() {
+			if r, _ := recover().(string); !strings.Contains(r, "modified") {
+				t.Fatalf("expected Next to panic, got %q", r)
+			}
+		}()
+		it.Next()
+	}()
+
+	// Delete requires a current element
+	for _, fn := range []func(){
+		func() { iter(m, &k, &v).Delete() },
+		func() { it := iter(m, &k, &v); it.Next(); it.Delete(); it.Delete() },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected Delete to panic without a current element")
+				}
+			}()
+			fn()
+		}()
+	}
+}
@@ -0,0 +1,99 @@
+//go:build go1.23
+// +build go1.23
+
+package randtest
+
+import (
+	"iter"
+	"strings"
+	"testing"
+)
+
+// All tests that all enumerates the map in uniformly random order.
+func All(t *testing.T, all func(map[int]int) iter.Seq2[int, int]) {
+	t.Helper()
+	const iters = 1000
+	m := IntMap(10)
+	counts := make([][]int, len(m))
+	for i := range counts {
+		counts[i] = make([]int, len(m))
+	}
+	seq := all(m)
+	for i := 0; i < iters; i++ {
+		j := 0
+		for k, v := range seq {
+			if k != v {
+				t.Fatalf("mismatched key/value pair: %v/%v", k, v)
+			}
+			// key k appeared at index j
+			counts[k][j]++
+			j++
+		}
+		if j != len(m) {
+			t.Fatalf("expected %v elements, got %v", len(m), j)
+		}
+	}
+
+	// each key should have appeared at each index about iters/len(m) times
+	for k, cs := range counts {
+		for i, c := range cs {
+			if (iters/len(m))/2 > c || c > (iters/len(m))*2 {
+				t.Errorf("suspicious count for key %v index %v: expected %v-%v, got %v", k, i, (iters/len(m))/2, (iters/len(m))*2, c)
+			}
+		}
+	}
+
+	for range all(map[int]int{}) {
+		t.Fatal("sequence over empty map should be empty")
+	}
+}
+
+// KeysValues tests that keys and values enumerate matching keys and values.
+func KeysValues(t *testing.T, keys, values func(map[int]int) iter.Seq[int]) {
+	t.Helper()
+	m := make(map[int]int)
+	for i := 0; i < 100; i++ {
+		m[i] = -i
+	}
+	seen := make(map[int]bool)
+	for k := range keys(m) {
+		seen[k] = true
+	}
+	for v := range values(m) {
+		if !seen[-v] {
+			t.Fatal("value without a matching key:", v)
+		}
+		delete(seen, -v)
+	}
+	if len(seen) != 0 {
+		t.Fatalf("%v keys had no matching value", len(seen))
+	}
+}
+
+// AllBreak tests that keys stops when the loop breaks.
+func AllBreak(t *testing.T, keys func(map[int]int) iter.Seq[int]) {
+	t.Helper()
+	n := 0
+	for range keys(IntMap(100)) {
+		if n++; n == 10 {
+			break
+		}
+	}
+	if n != 10 {
+		t.Fatalf("expected to stop after 10 elements, got %v", n)
+	}
+}
+
+// AllModified tests that all panics if the loop body modifies the map.
+func AllModified(t *testing.T, all func(map[int]int) iter.Seq2[int, int]) {
+	t.Helper()
+	m := IntMap(100)
+	defer func() {
+		if r, _ := recover().(string); !strings.Contains(r, "modified") {
+			t.Fatalf("expected iteration to panic, got %q", r)
+		}
+	}()
+	for k := range all(m) {
+		m[-k-1] = 0
+	}
+}
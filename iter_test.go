@@ -0,0 +1,64 @@
+//go:build !purego
+// +build !purego
+
+package randmap
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestIterAllocs(t *testing.T) {
+	if b, _ := Backend(); b != "unsafe" {
+		t.Skip("the safe backend allocates during iteration")
+	} else if checkMode {
+		t.Skip("check mode allocates during iteration")
+	}
+	type entry struct {
+		name string
+		tags []string
+	}
+	for _, n := range iterSizes {
+		ints := make(map[int]int)
+		ptrs := make(map[string]*entry)
+		for i := 0; i < n; i++ {
+			ints[i] = i
+			s := strconv.Itoa(i)
+			ptrs[s] = &entry{s, []string{s}}
+		}
+
+		var k, v int
+		it := FastIter(ints, &k, &v)
+		if a := testing.AllocsPerRun(n/2, func() { it.Next() }); a != 0 {
+			t.Errorf("Next allocated %v times per call on map[int]int", a)
+		}
+		var ks string
+		var vs *entry
+		it = FastIter(ptrs, &ks, &vs)
+		if a := testing.AllocsPerRun(n/2, func() { it.Next() }); a != 0 {
+			t.Errorf("Next allocated %v times per call on map[string]*entry", a)
+		}
+		// the copied entries must survive a GC
+		runtime.GC()
+		if vs.name != ks || vs.tags[0] != ks {
+			t.Errorf("Next copied a corrupt entry: %q -> %+v", ks, *vs)
+		}
+	}
+}
+
+func TestIterConcurrentWrite(t *testing.T) {
+	m := map[int]int{0: 0, 1: 1, 2: 2}
+	var k, v int
+	it := FastIter(m, &k, &v)
+	// simulate a write in progress on another goroutine
+	it.si.h.setWriting(true)
+	defer it.si.h.setWriting(false)
+	defer func() {
+		if r, _ := recover().(string); !strings.Contains(r, "concurrent map iteration and map write") {
+			t.Fatalf("expected Next to panic, got %q", r)
+		}
+	}()
+	it.Next()
+}
@@ -0,0 +1,14 @@
+//go:build !purego
+// +build !purego
+
+package randmap
+
+import "unsafe"
+
+// typedmemmove copies a value of type typ from src to dst. Unlike a plain
+// memmove, it invokes the GC write barriers required when the value contains
+// pointers. The runtime provides it for package reflect.
+//
+//go:linkname typedmemmove reflect.typedmemmove
+//go:noescape
+func typedmemmove(typ *_type, dst, src unsafe.Pointer)
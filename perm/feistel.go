@@ -15,6 +15,8 @@
 package perm
 
 import (
+	"encoding/binary"
+	"errors"
 	"hash"
 	"math/rand"
 
@@ -29,16 +31,39 @@ type feistelGenerator struct {
 	halfNumBits uint32
 	leftMask    uint32
 	rightMask   uint32
-	seed        uint32
 	numElems    uint32
 	i           uint32
 
 	hash  hash.Hash
 	arena [32]byte
+	// input to the round function: the key, followed by the round number
+	// and the right half of the index
+	data [KeySize + 5]byte
+	// if seeded is set, the generator was created by NewGenerator, and uses
+	// the original round function, which hashes the right half of the index
+	// together with seed plus the round number
+	seeded bool
+	seed   uint32
 }
 
+// KeySize is the size of a generator key, in bytes.
+const KeySize = 32
+
 // NewGenerator returns a new Feistel network-based permutation generator.
+// Since the seed is only 32 bits, it can select at most 2^32 permutations;
+// use NewKeyedGenerator when the permutation must be unpredictable. A given
+// seed always produces the same permutation as in earlier versions of this
+// package.
 func NewGenerator(numElems, seed uint32) *feistelGenerator {
+	f := NewKeyedGenerator(numElems, [KeySize]byte{})
+	f.seeded = true
+	f.seed = seed
+	return f
+}
+
+// NewKeyedGenerator returns a new Feistel network-based permutation generator
+// whose round function is keyed by a 256-bit key.
+func NewKeyedGenerator(numElems uint32, key [KeySize]byte) *feistelGenerator {
 	nextPow4 := uint32(4)
 	log4 := uint32(1)
 	for nextPow4 < numElems {
@@ -46,18 +71,23 @@ func NewGenerator(numElems, seed uint32) *feistelGenerator {
 		log4++
 	}
 
-	return &feistelGenerator{
+	f := &feistelGenerator{
 		nextPow4:    nextPow4,
 		halfNumBits: log4,
 		leftMask:    ((uint32(1) << log4) - 1) << log4, // e.g. 0xFFFF0000
 		rightMask:   (uint32(1) << log4) - 1,           // e.g. 0x0000FFFF
-		seed:        seed,
 		numElems:    numElems,
 
 		hash: blake2b.New256(),
 	}
+	copy(f.data[:], key[:])
+	return f
 }
 
+// Next returns the next element of the permutation. The Feistel network
+// permutes [0,nextPow4); indices that it maps outside of [0,numElems) are
+// skipped ("cycle-walking") rather than reduced modulo numElems, since
+// reducing them would yield some indices twice and others not at all.
 func (f *feistelGenerator) Next() (uint32, bool) {
 	for f.i < f.nextPow4 {
 		n := f.encryptIndex(f.i)
@@ -69,22 +99,91 @@ func (f *feistelGenerator) Next() (uint32, bool) {
 	return 0, false
 }
 
+// Reset restarts the permutation from the beginning.
+func (f *feistelGenerator) Reset() { f.i = 0 }
+
+// MarshalBinary implements encoding.BinaryMarshaler. The encoding contains
+// the generator's key (or seed), so it must be kept secret if the permutation
+// must be unpredictable.
+func (f *feistelGenerator) MarshalBinary() ([]byte, error) {
+	if f.seeded {
+		b := make([]byte, 12)
+		binary.LittleEndian.PutUint32(b[0:], f.numElems)
+		binary.LittleEndian.PutUint32(b[4:], f.i)
+		binary.LittleEndian.PutUint32(b[8:], f.seed)
+		return b, nil
+	}
+	b := make([]byte, 8+KeySize)
+	binary.LittleEndian.PutUint32(b[0:], f.numElems)
+	binary.LittleEndian.PutUint32(b[4:], f.i)
+	copy(b[8:], f.data[:KeySize])
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It restores f to the
+// state encoded by MarshalBinary, after which f continues the same
+// permutation from the same position.
+func (f *feistelGenerator) UnmarshalBinary(b []byte) error {
+	if len(b) != 8+KeySize && len(b) != 12 {
+		return errors.New("perm: invalid generator encoding length")
+	}
+	numElems := binary.LittleEndian.Uint32(b[0:])
+	if numElems > 1<<30 {
+		// nextPow4 would overflow
+		return errors.New("perm: invalid generator size")
+	}
+	var g *feistelGenerator
+	if len(b) == 12 {
+		g = NewGenerator(numElems, binary.LittleEndian.Uint32(b[8:]))
+	} else {
+		var key [KeySize]byte
+		copy(key[:], b[8:])
+		g = NewKeyedGenerator(numElems, key)
+	}
+	g.i = binary.LittleEndian.Uint32(b[4:])
+	if g.i > g.nextPow4 {
+		return errors.New("perm: invalid generator position")
+	}
+	*f = *g
+	return nil
+}
+
 func (f *feistelGenerator) encryptIndex(index uint32) uint32 {
 	// split index into left and right bits
 	left := (index & f.leftMask) >> f.halfNumBits
 	right := (index & f.rightMask)
 
 	// do 4 Feistel rounds
-	for i := uint32(0); i < 4; i++ {
-		ki := f.seed + i
-		left, right = right, left^f.round(right, ki)
+	for i := uint8(0); i < 4; i++ {
+		left, right = right, left^f.round(right, i)
 	}
 
 	// join left and right bits to form permuted index
 	return (left << f.halfNumBits) | right
 }
 
-func (f *feistelGenerator) round(right uint32, subkey uint32) uint32 {
+func (f *feistelGenerator) round(right uint32, i uint8) uint32 {
+	if f.seeded {
+		return f.seededRound(right, f.seed+uint32(i))
+	}
+	// Hashing the key along with the data makes blake2b a keyed PRF; unlike
+	// blake2b's native keying, this fits in a single block.
+	data := f.data[KeySize:]
+	data[0] = i
+	data[1] = byte(right >> 24)
+	data[2] = byte(right >> 16)
+	data[3] = byte(right >> 8)
+	data[4] = byte(right)
+	f.hash.Reset()
+	f.hash.Write(f.data[:])
+	sum := f.hash.Sum(f.arena[:0])
+
+	r := uint32(sum[0])<<24 | uint32(sum[1])<<16 | uint32(sum[2])<<8 | uint32(sum[3])
+	return r & f.rightMask
+}
+
+// seededRound is the round function of generators created by NewGenerator.
+func (f *feistelGenerator) seededRound(right uint32, subkey uint32) uint32 {
 	data := f.arena[:8]
 	data[0] = byte(right >> 24)
 	data[1] = byte(right >> 16)
@@ -95,7 +194,7 @@ func (f *feistelGenerator) round(right uint32, subkey uint32) uint32 {
 	data[6] = byte(subkey >> 8)
 	data[7] = byte(subkey)
 	f.hash.Reset()
-	f.hash.Write(data[:8])
+	f.hash.Write(data)
 	data = f.hash.Sum(f.arena[:0])
 
 	r := uint32(data[0])<<24 | uint32(data[1])<<16 | uint32(data[2])<<8 | uint32(data[3])
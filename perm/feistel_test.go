@@ -1,6 +1,7 @@
 package perm
 
 import (
+	"encoding/binary"
 	"math/rand"
 	"testing"
 )
@@ -33,3 +34,109 @@ func TestGenerator(t *testing.T) {
 		}
 	}
 }
+
+func TestGeneratorCompat(t *testing.T) {
+	// NewGenerator must produce the same permutations as earlier versions
+	for n, exp := range map[uint32][]uint32{
+		10:  {1, 6, 5, 4, 0, 3, 2, 7, 9, 8},
+		100: {9, 39, 8, 72, 55, 7, 87, 98, 62, 81},
+	} {
+		g := NewGenerator(n, 42)
+		for i, e := range exp {
+			if u, _ := g.Next(); u != e {
+				t.Fatalf("%v: expected %v at index %v, got %v", n, e, i, u)
+			}
+		}
+	}
+}
+
+func TestKeyedGenerator(t *testing.T) {
+	const numElems = 1000
+	var key [KeySize]byte
+	rand.Read(key[:])
+	seen := make([]bool, numElems)
+	g := NewKeyedGenerator(numElems, key)
+	for {
+		u, ok := g.Next()
+		if !ok {
+			break
+		} else if seen[u] {
+			t.Fatalf("%v appeared twice", u)
+		}
+		seen[u] = true
+	}
+	for u, ok := range seen {
+		if !ok {
+			t.Fatalf("%v never appeared", u)
+		}
+	}
+
+	// keys that differ only in their last byte should produce different
+	// permutations
+	key2 := key
+	key2[KeySize-1] ^= 1
+	g1, g2 := NewKeyedGenerator(numElems, key), NewKeyedGenerator(numElems, key2)
+	same := true
+	for i := 0; i < 10; i++ {
+		u1, _ := g1.Next()
+		u2, _ := g2.Next()
+		same = same && u1 == u2
+	}
+	if same {
+		t.Fatal("generator ignores part of its key")
+	}
+}
+
+func TestGeneratorMarshal(t *testing.T) {
+	const numElems = 1000
+	var key [KeySize]byte
+	rand.Read(key[:])
+	g := NewKeyedGenerator(numElems, key)
+	for i := 0; i < numElems/2; i++ {
+		g.Next()
+	}
+	b, err := g.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g2 := NewGenerator(0, 0)
+	if err := g2.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		u1, ok1 := g.Next()
+		u2, ok2 := g2.Next()
+		if u1 != u2 || ok1 != ok2 {
+			t.Fatal("restored generator diverged")
+		} else if !ok1 {
+			break
+		}
+	}
+
+	// seeded generators can be restored too
+	g = NewGenerator(numElems, 42)
+	g.Next()
+	b2, _ := g.MarshalBinary()
+	g3 := NewGenerator(0, 0)
+	if err := g3.UnmarshalBinary(b2); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		u1, ok1 := g.Next()
+		u2, ok2 := g3.Next()
+		if u1 != u2 || ok1 != ok2 {
+			t.Fatal("restored seeded generator diverged")
+		} else if !ok1 {
+			break
+		}
+	}
+
+	if err := g2.UnmarshalBinary(b[:len(b)-1]); err == nil {
+		t.Fatal("expected error for truncated encoding")
+	}
+	big := append([]byte(nil), b...)
+	binary.LittleEndian.PutUint32(big, 1<<30+1)
+	if err := g2.UnmarshalBinary(big); err == nil {
+		t.Fatal("expected error for oversized generator")
+	}
+}
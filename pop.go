@@ -0,0 +1,44 @@
+//go:build !purego
+// +build !purego
+
+package randmap
+
+import (
+	"reflect"
+
+	crand "crypto/rand"
+
+	safe "github.com/lukechampine/randmap/safe"
+)
+
+func randPop(m interface{}, read randReader) (k, v interface{}) {
+	it := randEntry(m, read)
+	mt := reflect.TypeOf(m)
+	// copy the entry out of the map before deleting it
+	key := reflect.New(mt.Key()).Elem()
+	key.Set(reflect.NewAt(mt.Key(), it.key).Elem())
+	v = reflect.NewAt(mt.Elem(), it.value).Elem().Interface()
+	// delete through the runtime, so that the slot is cleared properly
+	reflect.ValueOf(m).SetMapIndex(key, reflect.Value{})
+	return key.Interface(), v
+}
+
+// Pop removes a uniform random entry from m, which must be a non-empty map,
+// and returns its key and value. As with the builtin delete, an entry whose
+// key is not equal to itself (such as NaN) is returned but not removed.
+func Pop(m interface{}) (k, v interface{}) {
+	if probeErr != nil {
+		return safe.Pop(m)
+	}
+	return randPop(m, crand.Read)
+}
+
+// FastPop removes a pseudorandom entry from m, which must be a non-empty map,
+// and returns its key and value. As with the builtin delete, an entry whose
+// key is not equal to itself (such as NaN) is returned but not removed.
+func FastPop(m interface{}) (k, v interface{}) {
+	if probeErr != nil {
+		return safe.FastPop(m)
+	}
+	return randPop(m, fastRead)
+}
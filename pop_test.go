@@ -0,0 +1,57 @@
+package randmap
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestPop(t *testing.T) {
+	m := make(map[string]*int)
+	for i := 0; i < 1000; i++ {
+		i := i
+		m[strconv.Itoa(i)] = &i
+	}
+	seen := make(map[string]bool)
+	for len(m) > 0 {
+		n := len(m)
+		k, v := FastPop(m)
+		if len(m) != n-1 {
+			t.Fatalf("Pop did not remove %v", k)
+		} else if _, ok := m[k.(string)]; ok {
+			t.Fatalf("Pop returned %v but did not remove it", k)
+		} else if seen[k.(string)] {
+			t.Fatalf("Pop returned %v twice", k)
+		} else if strconv.Itoa(*v.(*int)) != k {
+			t.Fatalf("Pop returned mismatched entry %v: %v", k, *v.(*int))
+		}
+		seen[k.(string)] = true
+		if n%100 == 0 {
+			runtime.GC()
+		}
+	}
+	if len(seen) != 1000 {
+		t.Fatal("expected to pop 1000 entries, got", len(seen))
+	}
+
+	// nil interface keys must be deletable too
+	im := map[interface{}]int{nil: 0}
+	if k, _ := Pop(im); k != nil || len(im) != 0 {
+		t.Fatal("failed to pop nil key")
+	}
+
+	// the popped entry should be uniform
+	counts := make([]int, 5)
+	for i := 0; i < 10000; i++ {
+		k, _ := Pop(map[int]int{0: 0, 1: 1, 2: 2, 3: 3, 4: 4})
+		counts[k.(int)]++
+	}
+	checkUniform(t, "Pop", counts)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic when popping from empty map")
+		}
+	}()
+	Pop(make(map[int]int))
+}
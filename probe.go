@@ -0,0 +1,119 @@
+//go:build !purego
+// +build !purego
+
+package randmap
+
+import (
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"strconv"
+	"unsafe"
+)
+
+// maxProbeSlots bounds the slot space walked by probeMap. A correctly
+// decoded map of the sizes used by probe is far smaller than this.
+const maxProbeSlots = 1 << 20
+
+// probeErr is non-nil if the live runtime's map layout does not match the
+// layout randmap was built against. In that case, every function falls back
+// to the reflect-based implementation in randmap/safe.
+var probeErr = probe()
+
+// probing is set while probe runs. Until the layout has been verified, the
+// access path must not write to a map header, since a wrong offset would
+// corrupt the map instead of faulting.
+var probing bool
+
+// Backend reports which implementation randmap is using. It returns "unsafe"
+// and a nil error if the runtime's map layout was verified at init. Otherwise
+// it returns "safe" along with the reason that verification failed.
+func Backend() (string, error) {
+	if probeErr != nil {
+		return "safe", probeErr
+	}
+	return "unsafe", nil
+}
+
+// probe builds a few maps of known contents and checks that reading them
+// through the runtime mirror yields exactly the inserted entries.
+func probe() (err error) {
+	probing = true
+	defer func() { probing = false }()
+	// a misread pointer should fail the probe, not crash the program
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("randmap: runtime probe panicked: %v", r)
+		}
+	}()
+
+	// cover small maps, overflow, and (for bucket maps) maps that are in the
+	// middle of growing
+	for _, n := range []int{0, 1, 8, 9, 417, 5000} {
+		ints := make(map[int]int)
+		strs := make(map[string]*int)
+		for i := 0; i < n; i++ {
+			ints[i] = ^i
+			strs[strconv.Itoa(i)] = &i
+		}
+		if err := probeMap(ints); err != nil {
+			return err
+		}
+		if err := probeMap(strs); err != nil {
+			return err
+		}
+	}
+	// large keys and values are stored indirectly
+	big := make(map[[200]byte][300]byte)
+	for i := 0; i < 50; i++ {
+		var k [200]byte
+		var v [300]byte
+		k[i], v[i] = 1, byte(i)
+		big[k] = v
+	}
+	return probeMap(big)
+}
+
+// probeMap walks every slot of m, checking that the occupied slots hold
+// exactly the entries of m.
+func probeMap(m interface{}) error {
+	mv := reflect.ValueOf(m)
+	kt, vt := mv.Type().Key(), mv.Type().Elem()
+
+	ei := (*emptyInterface)(unsafe.Pointer(&m))
+	t := (*maptype)(ei.typ)
+	h := (*hmap)(ei.val)
+	if n := h.length(); n != mv.Len() {
+		return fmt.Errorf("randmap: %v has length %v, but runtime reports %v", mv.Type(), mv.Len(), n)
+	} else if n == 0 {
+		return nil
+	}
+
+	s := newSlotSpace(t, h)
+	if s.size() == 0 || s.size() > maxProbeSlots {
+		return fmt.Errorf("randmap: %v of length %v has implausible slot space %v", mv.Type(), mv.Len(), s.size())
+	}
+	it := new(hiter)
+	seen := make(map[interface{}]struct{})
+	for r := uintptr(0); r < s.size(); r++ {
+		if !s.access(t, h, it, r) {
+			continue
+		}
+		k := reflect.NewAt(kt, it.key).Elem()
+		v := mv.MapIndex(k)
+		if !v.IsValid() {
+			return fmt.Errorf("randmap: slot %v of %v holds a key that is not in the map", r, mv.Type())
+		} else if !reflect.DeepEqual(v.Interface(), reflect.NewAt(vt, it.value).Elem().Interface()) {
+			return fmt.Errorf("randmap: slot %v of %v holds the wrong value for its key", r, mv.Type())
+		}
+		if _, ok := seen[k.Interface()]; ok {
+			return fmt.Errorf("randmap: slot %v of %v holds a duplicate key", r, mv.Type())
+		}
+		seen[k.Interface()] = struct{}{}
+	}
+	if len(seen) != mv.Len() {
+		return fmt.Errorf("randmap: found %v entries in %v of length %v", len(seen), mv.Type(), mv.Len())
+	}
+	return nil
+}
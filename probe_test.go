@@ -0,0 +1,90 @@
+//go:build !purego
+// +build !purego
+
+package randmap
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+)
+
+func TestBackend(t *testing.T) {
+	if name, err := Backend(); name != "unsafe" || err != nil {
+		t.Fatalf("runtime probe failed: %v (%v)", name, err)
+	}
+}
+
+var probeSink interface{}
+
+func TestProbeReadOnly(t *testing.T) {
+	defer func(p bool) { probing = p }(probing)
+	probing = true
+
+	// a map without overflow buckets has no extra fields yet
+	m := make(map[int]int)
+	for i := 0; i < 8; i++ {
+		m[i] = i
+	}
+	// keep m off the stack, which may move while the probe runs
+	probeSink = m
+	h := *(**hmap)(unsafe.Pointer(&m))
+	header := func() []byte {
+		b := make([]byte, unsafe.Sizeof(*h))
+		for i := range b {
+			b[i] = *(*byte)(unsafe.Pointer(uintptr(unsafe.Pointer(h)) + uintptr(i)))
+		}
+		return b
+	}
+	before := header()
+	if err := probeMap(m); err != nil {
+		t.Fatal(err)
+	} else if string(header()) != string(before) {
+		t.Fatal("probe wrote to the map header")
+	}
+}
+
+func TestFallback(t *testing.T) {
+	defer func(err error) { probeErr = err }(probeErr)
+	probeErr = errors.New("simulated probe failure")
+	if name, err := Backend(); name != "safe" || err != probeErr {
+		t.Fatalf("expected safe backend, got %v (%v)", name, err)
+	}
+
+	m := map[int]int{0: 0, 1: 1, 2: 2}
+	if k := Key(m).(int); k < 0 || k > 2 {
+		t.Fatal("bad key:", k)
+	}
+	if v := FastVal(m).(int); v < 0 || v > 2 {
+		t.Fatal("bad value:", v)
+	}
+	var k, v int
+	n := 0
+	for it := Iter(m, &k, &v); it.Next(); n++ {
+		if k != v {
+			t.Fatalf("mismatched key/value pair: %v/%v", k, v)
+		}
+	}
+	if n != len(m) {
+		t.Fatalf("expected to visit %v elements, visited %v", len(m), n)
+	}
+}
+
+func TestLayoutStable(t *testing.T) {
+	// inserting and deleting entries without resizing must not invalidate a
+	// Sampler's cached slot space
+	m := make(map[int]int, 1000)
+	for i := 0; i < 10; i++ {
+		m[i] = i
+	}
+	h := *(**hmap)(unsafe.Pointer(&m))
+	l := h.layout()
+	m[10] = 10
+	delete(m, 0)
+	if h.layout() != l {
+		t.Fatal("layout changed without resizing the map")
+	}
+	if a := testing.AllocsPerRun(10, func() { h.stamp() }); a != 0 {
+		t.Errorf("stamp allocated %v times", a)
+	}
+}
@@ -0,0 +1,138 @@
+//go:build purego
+// +build purego
+
+package randmap
+
+import (
+	"errors"
+	"io"
+
+	safe "github.com/lukechampine/randmap/safe"
+)
+
+// When built with the purego tag, randmap does not use unsafe at all. Every
+// function is instead backed by the reflect-based implementation in
+// randmap/safe.
+
+var errPurego = errors.New("randmap: built with the purego tag")
+
+// Backend reports which implementation randmap is using. When built with the
+// purego tag, it always returns "safe".
+func Backend() (string, error) { return "safe", errPurego }
+
+// An Iterator iterates over a map in random or pseudorandom order. It is
+// intended to be used in a for loop like so:
+//
+//	m := make(map[int]int)
+//	var k, v int
+//	i := Iterator(m, &k, &v)
+//	for i.Next() {
+//	    // use k and v
+//	}
+type Iterator = safe.Iterator
+
+// Key returns a uniform random key of m, which must be a non-empty map.
+func Key(m interface{}) interface{} { return safe.Key(m) }
+
+// Val returns a uniform random value of m, which must be a non-empty map.
+func Val(m interface{}) interface{} { return safe.Val(m) }
+
+// Entry returns a uniform random key of m along with its value. m must be a
+// non-empty map.
+func Entry(m interface{}) (k, v interface{}) { return safe.Entry(m) }
+
+// Iter returns a random iterator for m. Each call to Next will store the next
+// key/value pair in k and v, which must be pointers. The map must not be
+// modified during iteration; see Iterator.Next.
+func Iter(m, k, v interface{}) *Iterator { return safe.Iter(m, k, v) }
+
+// FastKey returns a pseudorandom key of m, which must be a non-empty map.
+func FastKey(m interface{}) interface{} { return safe.FastKey(m) }
+
+// FastVal returns a pseudorandom value of m, which must be a non-empty map.
+func FastVal(m interface{}) interface{} { return safe.FastVal(m) }
+
+// FastEntry returns a pseudorandom key of m along with its value. m must be a
+// non-empty map.
+func FastEntry(m interface{}) (k, v interface{}) { return safe.FastEntry(m) }
+
+// FastIter returns a pseudorandom iterator for m. Each call to Next will
+// store the next key/value pair in k and v, which must be pointers. The map
+// must not be modified during iteration; see Iterator.Next.
+func FastIter(m, k, v interface{}) *Iterator { return safe.FastIter(m, k, v) }
+
+// TolerantIter returns a random iterator for m, like Iter, except that the map
+// may be modified by the iterating goroutine between calls to Next. Every
+// entry that is present in the map for the whole iteration is yielded exactly
+// once; entries inserted during the iteration are not yielded.
+func TolerantIter(m, k, v interface{}) *Iterator { return safe.TolerantIter(m, k, v) }
+
+// FastTolerantIter returns a pseudorandom iterator for m that permits the map
+// to be modified between calls to Next; see TolerantIter.
+func FastTolerantIter(m, k, v interface{}) *Iterator { return safe.FastTolerantIter(m, k, v) }
+
+// Pop removes a uniform random entry from m, which must be a non-empty map,
+// and returns its key and value. As with the builtin delete, an entry whose
+// key is not equal to itself (such as NaN) is returned but not removed.
+func Pop(m interface{}) (k, v interface{}) { return safe.Pop(m) }
+
+// FastPop removes a pseudorandom entry from m, which must be a non-empty map,
+// and returns its key and value. As with the builtin delete, an entry whose
+// key is not equal to itself (such as NaN) is returned but not removed.
+func FastPop(m interface{}) (k, v interface{}) { return safe.FastPop(m) }
+
+// ErrResized is returned when resuming an Iterator from a checkpoint taken
+// from a different map, from the same map before it was resized, or in a
+// different process. When built with the purego tag, Iterators cannot be
+// checkpointed at all.
+var ErrResized = errors.New("randmap: checkpoint does not match the map; it was resized, is a different map, or was taken by another process")
+
+// A Rand selects random elements of maps, drawing its randomness from a
+// caller-supplied source. A Rand is not safe for concurrent use unless its
+// source is.
+type Rand = safe.Rand
+
+// New returns a Rand that draws randomness from r. The Rand panics if r
+// returns an error, e.g. because it has been exhausted.
+func New(r io.Reader) *Rand { return safe.New(r) }
+
+// A Sampler repeatedly selects random elements of a single map.
+type Sampler struct {
+	m    interface{}
+	fast bool
+}
+
+// Reset forces the Sampler to recompute the slot space of its map. When
+// built with the purego tag, it does nothing.
+func (s *Sampler) Reset() {}
+
+// Key returns a random key of the Sampler's map, which must be non-empty.
+func (s *Sampler) Key() interface{} {
+	if s.fast {
+		return safe.FastKey(s.m)
+	}
+	return safe.Key(s.m)
+}
+
+// Val returns a random value of the Sampler's map, which must be non-empty.
+func (s *Sampler) Val() interface{} {
+	if s.fast {
+		return safe.FastVal(s.m)
+	}
+	return safe.Val(s.m)
+}
+
+// Entry returns a random key of the Sampler's map along with its value. The
+// map must be non-empty.
+func (s *Sampler) Entry() (k, v interface{}) {
+	if s.fast {
+		return safe.FastEntry(s.m)
+	}
+	return safe.Entry(s.m)
+}
+
+// NewSampler returns a Sampler that selects uniform random elements of m.
+func NewSampler(m interface{}) *Sampler { return &Sampler{m: m} }
+
+// NewFastSampler returns a Sampler that selects pseudorandom elements of m.
+func NewFastSampler(m interface{}) *Sampler { return &Sampler{m: m, fast: true} }
@@ -0,0 +1,34 @@
+//go:build purego
+// +build purego
+
+package randmap
+
+import safe "github.com/lukechampine/randmap/safe"
+
+// KeysWithReplacement appends n uniform random keys of m, which must be a
+// non-empty map, to dst and returns the extended slice. The same key may be
+// selected more than once.
+func KeysWithReplacement(m interface{}, n int, dst []interface{}) []interface{} {
+	return safe.KeysWithReplacement(m, n, dst)
+}
+
+// KeysWithReplacementFunc calls fn on n uniform random keys of m, which must
+// be a non-empty map, stopping early if fn returns false. The same key may be
+// selected more than once.
+func KeysWithReplacementFunc(m interface{}, n int, fn func(k interface{}) bool) {
+	safe.KeysWithReplacementFunc(m, n, fn)
+}
+
+// FastKeysWithReplacement appends n pseudorandom keys of m, which must be a
+// non-empty map, to dst and returns the extended slice. The same key may be
+// selected more than once.
+func FastKeysWithReplacement(m interface{}, n int, dst []interface{}) []interface{} {
+	return safe.FastKeysWithReplacement(m, n, dst)
+}
+
+// FastKeysWithReplacementFunc calls fn on n pseudorandom keys of m, which
+// must be a non-empty map, stopping early if fn returns false. The same key
+// may be selected more than once.
+func FastKeysWithReplacementFunc(m interface{}, n int, fn func(k interface{}) bool) {
+	safe.FastKeysWithReplacementFunc(m, n, fn)
+}
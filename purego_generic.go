@@ -0,0 +1,72 @@
+//go:build go1.18 && purego
+// +build go1.18,purego
+
+package randmap
+
+import safe "github.com/lukechampine/randmap/safe"
+
+// A TypedIterator iterates over a map in random or pseudorandom order. It is
+// intended to be used in a for loop like so:
+//
+//	m := make(map[int]int)
+//	i := IterOf(m)
+//	for i.Next() {
+//	    // use i.Key() and i.Val()
+//	}
+type TypedIterator[K comparable, V any] struct {
+	fallback *safe.TypedIterator[K, V]
+}
+
+// Next advances the TypedIterator to the next element in the map. It returns
+// false when all of the elements have been enumerated.
+func (i *TypedIterator[K, V]) Next() bool { return i != nil && i.fallback.Next() }
+
+// Key returns the key of the current element.
+func (i *TypedIterator[K, V]) Key() K { return i.fallback.Key() }
+
+// Val returns the value of the current element.
+func (i *TypedIterator[K, V]) Val() V { return i.fallback.Val() }
+
+// KeyOf returns a uniform random key of m, which must be a non-empty map.
+func KeyOf[K comparable, V any](m map[K]V) K { return safe.KeyOf(m) }
+
+// ValOf returns a uniform random value of m, which must be a non-empty map.
+func ValOf[K comparable, V any](m map[K]V) V { return safe.ValOf(m) }
+
+// EntryOf returns a uniform random key of m, which must be a non-empty map,
+// along with its associated value.
+func EntryOf[K comparable, V any](m map[K]V) (K, V) { return safe.EntryOf(m) }
+
+// PopEntry removes a uniform random entry from m, which must be a non-empty
+// map, and returns its key and value. It is the type-safe equivalent of Pop.
+// As with the builtin delete, an entry whose key is not equal to itself (such
+// as NaN) is returned but not removed.
+func PopEntry[K comparable, V any](m map[K]V) (K, V) { return safe.PopEntry(m) }
+
+// IterOf returns a random iterator for m. Next panics if the map gains or
+// loses entries during iteration; see Iterator.Next.
+func IterOf[K comparable, V any](m map[K]V) *TypedIterator[K, V] {
+	return &TypedIterator[K, V]{fallback: safe.IterOf(m)}
+}
+
+// FastKeyOf returns a pseudorandom key of m, which must be a non-empty map.
+func FastKeyOf[K comparable, V any](m map[K]V) K { return safe.FastKeyOf(m) }
+
+// FastValOf returns a pseudorandom value of m, which must be a non-empty map.
+func FastValOf[K comparable, V any](m map[K]V) V { return safe.FastValOf(m) }
+
+// FastEntryOf returns a pseudorandom key of m, which must be a non-empty map,
+// along with its associated value.
+func FastEntryOf[K comparable, V any](m map[K]V) (K, V) { return safe.FastEntryOf(m) }
+
+// FastPopEntry removes a pseudorandom entry from m, which must be a non-empty
+// map, and returns its key and value. It is the type-safe equivalent of
+// FastPop. As with the builtin delete, an entry whose key is not equal to
+// itself (such as NaN) is returned but not removed.
+func FastPopEntry[K comparable, V any](m map[K]V) (K, V) { return safe.FastPopEntry(m) }
+
+// FastIterOf returns a pseudorandom iterator for m. Next panics if the map
+// gains or loses entries during iteration; see Iterator.Next.
+func FastIterOf[K comparable, V any](m map[K]V) *TypedIterator[K, V] {
+	return &TypedIterator[K, V]{fallback: safe.FastIterOf(m)}
+}
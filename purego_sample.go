@@ -0,0 +1,29 @@
+//go:build purego
+// +build purego
+
+package randmap
+
+import safe "github.com/lukechampine/randmap/safe"
+
+// SampleKeys returns n distinct keys of m, in random order. Every n-subset of
+// m's keys is equally likely to be selected. If m has fewer than n entries,
+// SampleKeys returns all of its keys.
+func SampleKeys(m interface{}, n int) []interface{} { return safe.SampleKeys(m, n) }
+
+// SampleEntries returns n distinct keys of m and their corresponding values,
+// in random order. Every n-subset of m's entries is equally likely to be
+// selected. If m has fewer than n entries, SampleEntries returns all of them.
+func SampleEntries(m interface{}, n int) (keys, vals []interface{}) {
+	return safe.SampleEntries(m, n)
+}
+
+// FastSampleKeys returns n distinct pseudorandom keys of m. If m has fewer
+// than n entries, FastSampleKeys returns all of its keys.
+func FastSampleKeys(m interface{}, n int) []interface{} { return safe.FastSampleKeys(m, n) }
+
+// FastSampleEntries returns n distinct pseudorandom keys of m and their
+// corresponding values. If m has fewer than n entries, FastSampleEntries
+// returns all of them.
+func FastSampleEntries(m interface{}, n int) (keys, vals []interface{}) {
+	return safe.FastSampleEntries(m, n)
+}
@@ -0,0 +1,36 @@
+//go:build go1.23 && purego
+// +build go1.23,purego
+
+package randmap
+
+import (
+	"iter"
+
+	safe "github.com/lukechampine/randmap/safe"
+)
+
+// All returns an iterator over the key/value pairs of m in random order. The
+// iteration panics if the map gains or loses entries before it completes,
+// even if the loop body itself modifies the map; see Iterator.Next.
+func All[K comparable, V any](m map[K]V) iter.Seq2[K, V] { return safe.All(m) }
+
+// Keys returns an iterator over the keys of m in random order. The iteration
+// panics if the map is modified before it completes; see All.
+func Keys[K comparable, V any](m map[K]V) iter.Seq[K] { return safe.Keys(m) }
+
+// Values returns an iterator over the values of m in random order. The
+// iteration panics if the map is modified before it completes; see All.
+func Values[K comparable, V any](m map[K]V) iter.Seq[V] { return safe.Values(m) }
+
+// FastAll returns an iterator over the key/value pairs of m in pseudorandom
+// order. The iteration panics if the map is modified before it completes; see
+// All.
+func FastAll[K comparable, V any](m map[K]V) iter.Seq2[K, V] { return safe.FastAll(m) }
+
+// FastKeys returns an iterator over the keys of m in pseudorandom order. The
+// iteration panics if the map is modified before it completes; see All.
+func FastKeys[K comparable, V any](m map[K]V) iter.Seq[K] { return safe.FastKeys(m) }
+
+// FastValues returns an iterator over the values of m in pseudorandom order.
+// The iteration panics if the map is modified before it completes; see All.
+func FastValues[K comparable, V any](m map[K]V) iter.Seq[V] { return safe.FastValues(m) }
@@ -0,0 +1,32 @@
+//go:build purego
+// +build purego
+
+package randmap
+
+import safe "github.com/lukechampine/randmap/safe"
+
+// KeyWhere returns a random key of m for which pred returns true. Each such
+// key is equally likely to be selected. If there is no such key, KeyWhere
+// returns false. pred must not modify m.
+func KeyWhere(m interface{}, pred func(k interface{}) bool) (interface{}, bool) {
+	return safe.KeyWhere(m, pred)
+}
+
+// KeyExcept returns a random key of m that is not a key of exclude, which
+// must be a map (typically a set) with the same key type as m. Each such key
+// is equally likely to be selected. If there is no such key, KeyExcept
+// returns false.
+func KeyExcept(m, exclude interface{}) (interface{}, bool) { return safe.KeyExcept(m, exclude) }
+
+// FastKeyWhere returns a pseudorandom key of m for which pred returns true,
+// or false if there is no such key. pred must not modify m.
+func FastKeyWhere(m interface{}, pred func(k interface{}) bool) (interface{}, bool) {
+	return safe.FastKeyWhere(m, pred)
+}
+
+// FastKeyExcept returns a pseudorandom key of m that is not a key of exclude,
+// which must be a map with the same key type as m, or false if there is no
+// such key.
+func FastKeyExcept(m, exclude interface{}) (interface{}, bool) {
+	return safe.FastKeyExcept(m, exclude)
+}
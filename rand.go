@@ -0,0 +1,104 @@
+//go:build !purego
+// +build !purego
+
+package randmap
+
+import (
+	"io"
+
+	safe "github.com/lukechampine/randmap/safe"
+)
+
+// A Rand selects random elements of maps, drawing its randomness from a
+// caller-supplied source. A Rand is not safe for concurrent use unless its
+// source is.
+type Rand struct {
+	read randReader
+
+	// used instead of the above if the runtime probe failed
+	fallback *safe.Rand
+}
+
+// New returns a Rand that draws randomness from r. The Rand panics if r
+// returns an error, e.g. because it has been exhausted, since a short read
+// would silently bias its selections.
+//
+// Two Rands fed identical streams make identical selections from the same
+// map, provided the map is not modified in between. Note that Go lays out
+// each map differently, so selections are not reproducible across maps (or
+// processes), even if the maps have the same contents.
+func New(r io.Reader) *Rand {
+	if probeErr != nil {
+		return &Rand{fallback: safe.New(r)}
+	}
+	return &Rand{
+		read: func(p []byte) (int, error) {
+			if _, err := io.ReadFull(r, p); err != nil {
+				panic("randmap: reading from Rand source: " + err.Error())
+			}
+			return len(p), nil
+		},
+	}
+}
+
+// Key returns a random key of m, which must be a non-empty map.
+func (r *Rand) Key(m interface{}) interface{} {
+	if r.fallback != nil {
+		return r.fallback.Key(m)
+	}
+	return randKey(m, r.read)
+}
+
+// Val returns a random value of m, which must be a non-empty map.
+func (r *Rand) Val(m interface{}) interface{} {
+	if r.fallback != nil {
+		return r.fallback.Val(m)
+	}
+	return randVal(m, r.read)
+}
+
+// Entry returns a random key of m along with its value. m must be a non-empty
+// map.
+func (r *Rand) Entry(m interface{}) (k, v interface{}) {
+	if r.fallback != nil {
+		return r.fallback.Entry(m)
+	}
+	return randKeyVal(m, r.read)
+}
+
+// Iter returns a random iterator for m. Each call to Next will store the next
+// key/value pair in k and v, which must be pointers. The map must not be
+// modified during iteration; see Iterator.Next.
+func (r *Rand) Iter(m, k, v interface{}) *Iterator {
+	if r.fallback != nil {
+		return &Iterator{fallback: r.fallback.Iter(m, k, v)}
+	}
+	return randIter(m, k, v, r.read)
+}
+
+// TolerantIter returns a random iterator for m that permits the map to be
+// modified between calls to Next; see the TolerantIter function.
+func (r *Rand) TolerantIter(m, k, v interface{}) *Iterator {
+	if r.fallback != nil {
+		return &Iterator{fallback: r.fallback.TolerantIter(m, k, v)}
+	}
+	return randTolerantIter(m, k, v, r.read)
+}
+
+// SampleKeys returns n distinct random keys of m; see the SampleKeys
+// function.
+func (r *Rand) SampleKeys(m interface{}, n int) []interface{} {
+	if r.fallback != nil {
+		return r.fallback.SampleKeys(m, n)
+	}
+	return randSampleKeys(m, n, r.read)
+}
+
+// SampleEntries returns n distinct random keys of m and their corresponding
+// values; see the SampleEntries function.
+func (r *Rand) SampleEntries(m interface{}, n int) (keys, vals []interface{}) {
+	if r.fallback != nil {
+		return r.fallback.SampleEntries(m, n)
+	}
+	return randSampleEntries(m, n, r.read)
+}
@@ -0,0 +1,39 @@
+//go:build go1.22
+// +build go1.22
+
+package randmap
+
+import (
+	"encoding/binary"
+	"math/rand/v2"
+)
+
+// sourceReader adapts a rand.Source to an io.Reader.
+type sourceReader struct {
+	src rand.Source
+}
+
+func (s sourceReader) Read(p []byte) (int, error) {
+	var buf [8]byte
+	for i := 0; i < len(p); i += len(buf) {
+		binary.LittleEndian.PutUint64(buf[:], s.src.Uint64())
+		copy(p[i:], buf[:])
+	}
+	return len(p), nil
+}
+
+// NewSource returns a Rand that draws randomness from src.
+func NewSource(src rand.Source) *Rand {
+	return New(sourceReader{src})
+}
+
+// NewSeed returns a Rand that draws randomness from a ChaCha8 generator
+// keyed by seed. Logging the seed allows a sequence of selections to be
+// replayed later, subject to the caveats described in New. Selections are
+// only replayed by the unsafe backend (see Backend); the randmap/safe
+// implementation visits keys in an order that varies from call to call.
+func NewSeed(seed uint64) *Rand {
+	var key [32]byte
+	binary.LittleEndian.PutUint64(key[:], seed)
+	return NewSource(rand.NewChaCha8(key))
+}
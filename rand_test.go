@@ -0,0 +1,109 @@
+//go:build go1.22
+// +build go1.22
+
+package randmap
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestRandReplay(t *testing.T) {
+	m := make(map[int]int)
+	for i := 0; i < 1000; i++ {
+		m[i] = i
+	}
+	if name, _ := Backend(); name != "unsafe" {
+		t.Skip("replay requires the unsafe backend")
+	}
+
+	r1, r2 := NewSeed(42), NewSeed(42)
+	for i := 0; i < 100; i++ {
+		if k1, k2 := r1.Key(m), r2.Key(m); k1 != k2 {
+			t.Fatalf("seeded Rands diverged after %v selections: %v != %v", i, k1, k2)
+		}
+		if v1, v2 := r1.Val(m), r2.Val(m); v1 != v2 {
+			t.Fatalf("seeded Rands diverged after %v selections: %v != %v", i, v1, v2)
+		}
+		k1, v1 := r1.Entry(m)
+		k2, v2 := r2.Entry(m)
+		if k1 != k2 || v1 != v2 {
+			t.Fatalf("seeded Rands diverged after %v selections: %v:%v != %v:%v", i, k1, v1, k2, v2)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		s1, s2 := r1.SampleKeys(m, 10), r2.SampleKeys(m, 10)
+		for j := range s1 {
+			if s1[j] != s2[j] {
+				t.Fatalf("seeded samples diverged: %v != %v", s1, s2)
+			}
+		}
+	}
+
+	var k1, k2, v int
+	it1, it2 := r1.Iter(m, &k1, &v), r2.Iter(m, &k2, &v)
+	for it1.Next() {
+		if !it2.Next() || k1 != k2 {
+			t.Fatal("seeded iterators diverged")
+		}
+	}
+	if it2.Next() {
+		t.Fatal("seeded iterators diverged")
+	}
+}
+
+func TestRandReader(t *testing.T) {
+	m := map[int]int{0: 0, 1: 1, 2: 2}
+	buf := make([]byte, 1<<16)
+	rand.New(rand.NewSource(0)).Read(buf)
+	r := New(bytes.NewReader(buf))
+	for i := 0; i < 100; i++ {
+		if k := r.Key(m).(int); k < 0 || k > 2 {
+			t.Fatal("bad key:", k)
+		}
+	}
+}
+
+func TestRandExhausted(t *testing.T) {
+	m := map[int]int{0: 0, 1: 1, 2: 2}
+	r := New(bytes.NewReader(make([]byte, 4)))
+	defer func() {
+		if r, _ := recover().(string); !strings.Contains(r, "Rand source") {
+			t.Fatalf("expected exhausted source to panic, got %q", r)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		r.Key(m)
+	}
+}
+
+func TestRandSample(t *testing.T) {
+	m := make(map[int]int)
+	for i := 0; i < 100; i++ {
+		m[i] = i
+	}
+	r := NewSeed(0)
+	keys := r.SampleKeys(m, 10)
+	if len(keys) != 10 {
+		t.Fatalf("expected 10 keys, got %v", len(keys))
+	}
+	seen := make(map[interface{}]bool)
+	for _, k := range keys {
+		if seen[k] {
+			t.Fatalf("key %v sampled twice", k)
+		}
+		seen[k] = true
+	}
+	keys, vals := r.SampleEntries(m, 200)
+	if len(keys) != len(m) {
+		t.Fatalf("expected %v entries, got %v", len(m), len(keys))
+	}
+	for i := range keys {
+		if m[keys[i].(int)] != vals[i].(int) {
+			t.Fatalf("key %v has wrong value %v", keys[i], vals[i])
+		}
+	}
+}
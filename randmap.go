@@ -1,15 +1,20 @@
+//go:build !purego
+// +build !purego
+
 // Package randmap provides methods for accessing random elements of maps, and
 // iterating through maps in random order.
 package randmap
 
 import (
+	"encoding/binary"
+	"math/bits"
 	"reflect"
 	"unsafe"
 
 	crand "crypto/rand"
-	mrand "math/rand"
 
 	"github.com/lukechampine/randmap/perm"
+	safe "github.com/lukechampine/randmap/safe"
 )
 
 const ptrSize = unsafe.Sizeof(uintptr(0))
@@ -23,57 +28,298 @@ type emptyInterface struct {
 // function instead of an io.Reader
 type randReader func(p []byte) (int, error)
 
-func randInts(read randReader, numBuckets uintptr, numOver uint8) (uintptr, uint8, uint8) {
-	space := numBuckets * uintptr(numOver) * bucketCnt
-	var arena [ptrSize]byte
-	read(arena[:])
-	r := *(*uintptr)(unsafe.Pointer(&arena[0])) % space
+// randIndex returns a uniform random value in [0, n). Reducing a random word
+// modulo n would favor small values whenever n does not divide 2^64, so
+// instead it uses Lemire's multiply-shift method: the high word of x*n is
+// uniform in [0, n), provided that the few values of x whose low word falls
+// below 2^64 mod n are rejected.
+func randIndex(read randReader, n uintptr) uintptr {
+	var arena [8]byte
+	for {
+		read(arena[:])
+		if r, ok := reduce64(binary.LittleEndian.Uint64(arena[:]), n); ok {
+			return r
+		}
+	}
+}
 
-	bucket := r / (uintptr(numOver) * bucketCnt)
-	over := (r / bucketCnt) % uintptr(numOver)
-	offi := r % bucketCnt
+// reduce64 maps the random word x to a uniform value in [0, n), as described
+// above. It returns false if x must be rejected.
+func reduce64(x uint64, n uintptr) (uintptr, bool) {
+	hi, lo := bits.Mul64(x, uint64(n))
+	// -n % n is 2^64 mod n; computing it costs a division, so skip it when lo
+	// is clearly large enough
+	return uintptr(hi), lo >= uint64(n) || lo >= -uint64(n)%uint64(n)
+}
 
-	return bucket, uint8(over), uint8(offi)
+// An indexer is a source of uniform random indices.
+type indexer interface {
+	index(n uintptr) uintptr
 }
 
-func randKey(m interface{}, src randReader) interface{} {
+func (read randReader) index(n uintptr) uintptr { return randIndex(read, n) }
+
+// reduce32 is the 32-bit analogue of randIndex: it maps the random word x to
+// a uniform value in [0, n), drawing a fresh word from read whenever x must
+// be rejected.
+func reduce32(x, n uint32, read randReader) uint32 {
+	for {
+		prod := uint64(x) * uint64(n)
+		if lo := uint32(prod); lo >= n || lo >= -n%n {
+			return uint32(prod >> 32)
+		}
+		var arena [4]byte
+		read(arena[:])
+		x = binary.LittleEndian.Uint32(arena[:])
+	}
+}
+
+const (
+	// maxProbes is the number of random slots randSlot will probe before
+	// falling back to a scan.
+	maxProbes = 1024
+
+	// If fewer than 1 in sparseRatio slots are occupied, randSlot skips
+	// probing entirely, since a probe would usually miss.
+	sparseRatio = 256
+)
+
+// randSlot moves 'it' to a uniform random occupied slot of s, returning the
+// index of the slot.
+//
+// Normally, randSlot probes random slots until it finds an occupied one. The
+// number of probes this takes is geometrically distributed, with mean
+// s.size()/count; after a mass deletion, that can be very large. So if the
+// map is sparse, or if maxProbes probes all miss, randSlot instead selects a
+// random rank in [0, count) and scans for the entry with that rank. Either
+// strategy selects a uniform entry, and hence so does their combination. In
+// the worst case, randSlot inspects maxProbes + s.size() slots.
+func randSlot(t *maptype, h *hmap, it *hiter, s slotSpace, idx indexer) uintptr {
+	count, size := uintptr(h.length()), s.size()
+	if count*sparseRatio >= size {
+		for i := 0; i < maxProbes; i++ {
+			if r := idx.index(size); s.access(t, h, it, r) {
+				return r
+			}
+		}
+	}
+	if r, ok := seekSlot(t, h, it, s, idx.index(count)); ok {
+		return r
+	}
+	// the map must have been modified concurrently; there's not much we can
+	// do but keep probing
+	r := idx.index(size)
+	for !s.access(t, h, it, r) {
+		r = idx.index(size)
+	}
+	return r
+}
+
+// seekSlot moves 'it' to the occupied slot of s with the given rank, i.e.
+// the occupied slot preceded by exactly rank other occupied slots. It returns
+// the index of the slot, or false if s has too few occupied slots.
+func seekSlot(t *maptype, h *hmap, it *hiter, s slotSpace, rank uintptr) (uintptr, bool) {
+	for r := uintptr(0); r < s.size(); r++ {
+		if s.access(t, h, it, r) {
+			if rank == 0 {
+				return r, true
+			}
+			rank--
+		}
+	}
+	return 0, false
+}
+
+// randEntry returns a hiter pointing to a uniform random entry of m, which
+// must be a non-empty map.
+func randEntry(m interface{}, read randReader) hiter {
 	ei := (*emptyInterface)(unsafe.Pointer(&m))
 	t := (*maptype)(ei.typ)
 	h := (*hmap)(ei.val)
-	if h == nil || h.count == 0 {
+	if h == nil || h.length() == 0 {
 		panic("empty map")
 	}
-	it := new(hiter)
-	numBuckets := uintptr(1) << h.B
-	numOver := maxOverflow(t, h) + 1
-	bucket, over, offi := randInts(src, numBuckets, numOver)
-	for !mapaccessi(t, h, it, bucket, over, offi) {
-		bucket, over, offi = randInts(src, numBuckets, numOver)
+	var it hiter
+	s := newSlotSpace(t, h)
+	r := randSlot(t, h, &it, s, read)
+	if checkMode {
+		checkSlot(reflect.ValueOf(m), &it, s, r)
 	}
-	return *(*interface{})(unsafe.Pointer(&emptyInterface{
-		typ: unsafe.Pointer(t.key),
-		val: it.key,
-	}))
+	return it
+}
+
+func randKey(m interface{}, src randReader) interface{} {
+	it := randEntry(m, src)
+	// copy the key out of the map; the slot may be reused later
+	return reflect.NewAt(reflect.TypeOf(m).Key(), it.key).Elem().Interface()
 }
 
 func randVal(m interface{}, src randReader) interface{} {
+	it := randEntry(m, src)
+	return reflect.NewAt(reflect.TypeOf(m).Elem(), it.value).Elem().Interface()
+}
+
+// randKeyVal returns the key and value of a single random slot of m.
+func randKeyVal(m interface{}, src randReader) (k, v interface{}) {
+	it := randEntry(m, src)
+	mt := reflect.TypeOf(m)
+	k = reflect.NewAt(mt.Key(), it.key).Elem().Interface()
+	v = reflect.NewAt(mt.Elem(), it.value).Elem().Interface()
+	return k, v
+}
+
+// A generator yields a permutation of a map's slots.
+type generator interface {
+	Next() (uint32, bool)
+	Reset()
+}
+
+// A slotIter visits the occupied slots of a map in the order given by a
+// permutation generator.
+type slotIter struct {
+	gen  generator
+	it   hiter
+	slot uint32 // the slot that it points to
+
+	// number of entries visited so far
+	visited int
+
+	// the map's layout and length when the permutation was generated
+	layout layout
+	count  int
+
+	// whether the map may be modified between calls to next; see
+	// TolerantIter
+	tolerant bool
+
+	// whether next tolerates entries moving to other slots, by remembering
+	// which entries were yielded; see track
+	tracking bool
+	place    placement
+	yielded  []uint64 // hashes of the keys yielded so far
+	sorted   int      // length of the prefix of yielded sorted by regenerate
+
+	// non-nil in check mode
+	check *iterCheck
+
+	// constants
+	t     *maptype
+	h     *hmap
+	read  randReader
+	space slotSpace
+}
+
+// snapshot records the map's current layout and length, so that next can
+// detect modifications.
+func (si *slotIter) snapshot() {
+	si.layout = si.h.layout()
+	si.count = si.h.length()
+}
+
+// checkUnmodified panics if the map has been modified since the last
+// snapshot. Otherwise, the permutation could refer to slots that no longer
+// exist, or that now hold different entries.
+func (si *slotIter) checkUnmodified() {
+	if si.h.layout() != si.layout || si.h.length() != si.count {
+		panic("randmap: map modified during iteration")
+	}
+}
+
+// next advances to the next occupied slot, storing pointers to its key and
+// value in si.it. It returns false when all of the slots have been visited.
+// Unless si is tolerant, it panics if the map has been modified since the
+// last snapshot.
+func (si *slotIter) next() bool {
+	for {
+		r, ok := si.gen.Next()
+		if !ok {
+			if si.check != nil {
+				si.check.done()
+			}
+			return false
+		}
+		if si.h.beingWritten() {
+			panic("randmap: concurrent map iteration and map write")
+		}
+		if !si.tolerant {
+			si.checkUnmodified()
+		}
+		if si.tracking && si.place.moved(si.t, si.h, si.space, uintptr(r)) {
+			si.regenerate()
+			continue
+		}
+		// r is only out of range if the generator was restored from a
+		// corrupt checkpoint
+		if uintptr(r) < si.space.size() && si.space.access(si.t, si.h, &si.it, uintptr(r)) {
+			if si.tracking && !si.firstVisit() {
+				continue
+			}
+			si.slot = r
+			if si.check != nil {
+				si.check.visit(&si.it, si.space, uintptr(r))
+			}
+			si.visited++
+			return true
+		}
+	}
+}
+
+// reset restarts the current permutation.
+func (si *slotIter) reset() {
+	si.gen.Reset()
+	si.visited = 0
+	si.yielded, si.sorted = si.yielded[:0], 0
+	if si.check != nil {
+		si.check = newIterCheck(si.check.m)
+	}
+}
+
+// permute recomputes the map's slot space and creates a fresh permutation of
+// it.
+func (si *slotIter) permute() {
+	si.snapshot()
+	if si.tracking {
+		si.place = newPlacement(si.t, si.h)
+	}
+	si.space = newSlotSpace(si.t, si.h)
+	if si.space.size() <= smallIter {
+		si.gen = newShuffleGenerator(si.t, si.h, si.space, si.read)
+	} else {
+		var key [perm.KeySize]byte
+		si.read(key[:])
+		si.gen = perm.NewKeyedGenerator(uint32(si.space.size()), key)
+	}
+}
+
+// reseed restarts the iteration with a fresh permutation.
+func (si *slotIter) reseed() {
+	si.permute()
+	si.visited = 0
+	si.yielded, si.sorted = si.yielded[:0], 0
+	if si.check != nil {
+		si.check = newIterCheck(si.check.m)
+	}
+}
+
+// newSlotIter returns a slotIter for m, seeded from read. It returns nil if m
+// is empty.
+func newSlotIter(m interface{}, read randReader) *slotIter {
 	ei := (*emptyInterface)(unsafe.Pointer(&m))
 	t := (*maptype)(ei.typ)
 	h := (*hmap)(ei.val)
-	if h == nil || h.count == 0 {
-		panic("empty map")
+	if h == nil || h.length() == 0 {
+		return nil
 	}
-	it := new(hiter)
-	numBuckets := uintptr(1) << h.B
-	numOver := maxOverflow(t, h) + 1
-	bucket, over, offi := randInts(src, numBuckets, numOver)
-	for !mapaccessi(t, h, it, bucket, over, offi) {
-		bucket, over, offi = randInts(src, numBuckets, numOver)
+	si := &slotIter{
+		t:    t,
+		h:    h,
+		read: read,
 	}
-	return *(*interface{})(unsafe.Pointer(&emptyInterface{
-		typ: unsafe.Pointer(t.elem),
-		val: it.value,
-	}))
+	if checkMode {
+		si.check = newIterCheck(reflect.ValueOf(m))
+	}
+	si.reseed()
+	return si
 }
 
 // An Iterator iterates over a map in random or pseudorandom order. It is
@@ -87,53 +333,141 @@ func randVal(m interface{}, src randReader) interface{} {
 //  }
 //
 type Iterator struct {
-	// permutation generator
-	gen interface {
-		Next() (uint32, bool)
-	}
+	si *slotIter
+	m  reflect.Value
+	// the caller's key and value variables
+	k, v unsafe.Pointer
+	// a copy of the current key, which Delete removes even if the caller has
+	// since changed k, and whether there is an entry that can be deleted
+	key  reflect.Value
+	keyp unsafe.Pointer
+	cur  bool
 
-	it   *hiter
-	k, v reflect.Value
-
-	// constants
-	t    *maptype
-	h    *hmap
-	over uint32
+	// used instead of the above if the runtime probe failed
+	fallback *safe.Iterator
 }
 
 // Next advances the Iterator to the next element in the map, storing its key
 // and value in the pointers passed during initialization. It returns false
 // when all of the elements have been enumerated.
+//
+// Like the runtime's own map iteration, Next panics if it detects that the
+// map is being written concurrently. It also panics if the map has gained or
+// lost entries, or has been resized, since the Iterator was created or last
+// reseeded. (Deleting one entry and inserting another in its place may go
+// unnoticed, however.) To continue iterating after modifying the map, call
+// Reseed.
 func (i *Iterator) Next() bool {
 	if i == nil {
 		return false
+	} else if i.fallback != nil {
+		return i.fallback.Next()
+	}
+	i.cur = i.si.next()
+	if !i.cur {
+		return false
 	}
-	t, h, it := i.t, i.h, i.it
+	typedmemmove(i.si.t.key, i.k, i.si.it.key)
+	typedmemmove(i.si.t.key, i.keyp, i.si.it.key)
+	typedmemmove(i.si.t.elem, i.v, i.si.it.value)
+	return true
+}
 
-	for {
-		r, ok := i.gen.Next()
-		if !ok {
-			return false
-		}
+// Delete removes the current element, i.e. the one most recently stored by
+// Next, from the map. The rest of the iteration still visits every remaining
+// element exactly once, even if deleting the element causes the map to move
+// its other elements. As with the builtin delete, an element whose key is not
+// equal to itself (such as NaN) is not removed. Delete panics if there is no
+// current element, or if it has already been deleted. After calling Delete,
+// the Iterator can no longer be checkpointed.
+//
+// Unless the Iterator was created by TolerantIter, the first call to Delete
+// replays the permutation up to the current element, in order to record the
+// elements already visited, so it takes time proportional to the number of
+// elements visited so far.
+func (i *Iterator) Delete() {
+	if i != nil && i.fallback != nil {
+		i.fallback.Delete()
+		return
+	} else if i == nil || !i.cur {
+		panic("randmap: Delete called without a current element")
+	}
+	i.cur = false
+	si := i.si
+	if !si.tolerant {
+		si.checkUnmodified()
+	}
+	if !si.tracking {
+		si.track()
+	}
+	// deleting through the runtime may also advance an in-progress grow
+	n := si.h.length()
+	i.m.SetMapIndex(i.key, reflect.Value{})
+	if si.h.length() != n {
+		si.visited--
+	}
+	if !si.tolerant {
+		// the map was only modified by us
+		si.snapshot()
+	}
+}
 
-		bucket := uintptr(r / (i.over * bucketCnt))
-		over := (r / bucketCnt) % i.over
-		offi := r % bucketCnt
-		if mapaccessi(t, h, it, bucket, uint8(over), uint8(offi)) {
-			// unfortunately, there doesn't seem to be a faster way than this
-			k := *(*interface{})(unsafe.Pointer(&emptyInterface{
-				typ: unsafe.Pointer(t.key),
-				val: it.key,
-			}))
-			v := *(*interface{})(unsafe.Pointer(&emptyInterface{
-				typ: unsafe.Pointer(t.elem),
-				val: it.value,
-			}))
-			i.k.Set(reflect.ValueOf(k))
-			i.v.Set(reflect.ValueOf(v))
-			return true
-		}
+// Reset restarts the Iterator, so that it enumerates the map's elements in
+// the same order again.
+func (i *Iterator) Reset() {
+	if i == nil {
+		return
+	} else if i.fallback != nil {
+		i.fallback.Reset()
+		return
+	}
+	i.cur = false
+	i.si.reset()
+}
+
+// Reseed restarts the Iterator with a fresh random order, drawn from the same
+// source of randomness as the original. Unlike Reset, Reseed may be called
+// after the map has been modified.
+func (i *Iterator) Reseed() {
+	if i == nil {
+		return
+	} else if i.fallback != nil {
+		i.fallback.Reseed()
+		return
+	}
+	i.cur = false
+	i.si.reseed()
+}
+
+// Skip advances the Iterator past the next n elements, without storing them.
+// It returns the number of elements skipped, which is less than n only if the
+// Iterator was exhausted.
+func (i *Iterator) Skip(n int) int {
+	if i == nil {
+		return 0
+	} else if i.fallback != nil {
+		return i.fallback.Skip(n)
+	}
+	i.cur = false
+	var skipped int
+	for skipped < n && i.si.next() {
+		skipped++
+	}
+	return skipped
+}
+
+// Remaining returns the number of elements that the Iterator has not yet
+// enumerated.
+func (i *Iterator) Remaining() int {
+	if i == nil {
+		return 0
+	} else if i.fallback != nil {
+		return i.fallback.Remaining()
+	}
+	if rem := i.si.h.length() - i.si.visited; rem > 0 {
+		return rem
 	}
+	return 0
 }
 
 func randIter(m, k, v interface{}, read randReader) *Iterator {
@@ -144,55 +478,91 @@ func randIter(m, k, v interface{}, read randReader) *Iterator {
 		panic("wrong type for v: expected " + exp.String() + ", got " + vt.String())
 	}
 
-	// determine total rand space for m
-	ei := (*emptyInterface)(unsafe.Pointer(&m))
-	t := (*maptype)(ei.typ)
-	h := (*hmap)(ei.val)
-	if h == nil || h.count == 0 {
+	si := newSlotIter(m, read)
+	if si == nil {
 		return nil
 	}
-	numOver := uint32(maxOverflow(t, h) + 1)
-	numBuckets := uint32(1 << h.B)
-	space := numBuckets * numOver * bucketCnt
-
-	// create a permutation generator for the space
-	var seed [4]byte
-	read(seed[:])
-	g := perm.NewGenerator(space, *(*uint32)(unsafe.Pointer(&seed[0])))
-
-	// grab pointers to k and v's memory
-	kptr := reflect.ValueOf(k).Elem()
-	vptr := reflect.ValueOf(v).Elem()
 
+	// k and v are pointers, so their interface data words point directly to
+	// the caller's variables
+	key := reflect.New(mt.Key())
 	return &Iterator{
-		gen:  g,
-		it:   new(hiter),
-		k:    kptr,
-		v:    vptr,
-		t:    t,
-		h:    h,
-		over: numOver,
+		si:   si,
+		m:    reflect.ValueOf(m),
+		k:    (*emptyInterface)(unsafe.Pointer(&k)).val,
+		v:    (*emptyInterface)(unsafe.Pointer(&v)).val,
+		key:  key.Elem(),
+		keyp: unsafe.Pointer(key.Pointer()),
 	}
 }
 
 // Key returns a uniform random key of m, which must be a non-empty map.
-func Key(m interface{}) interface{} { return randKey(m, crand.Read) }
+func Key(m interface{}) interface{} {
+	if probeErr != nil {
+		return safe.Key(m)
+	}
+	return randKey(m, crand.Read)
+}
 
 // Val returns a uniform random value of m, which must be a non-empty map.
-func Val(m interface{}) interface{} { return randVal(m, crand.Read) }
+func Val(m interface{}) interface{} {
+	if probeErr != nil {
+		return safe.Val(m)
+	}
+	return randVal(m, crand.Read)
+}
+
+// Entry returns a uniform random key of m along with its value. m must be a
+// non-empty map. Unlike calling Key and then indexing m, Entry reads both from
+// the same slot, without a second lookup.
+func Entry(m interface{}) (k, v interface{}) {
+	if probeErr != nil {
+		return safe.Entry(m)
+	}
+	return randKeyVal(m, crand.Read)
+}
 
 // Iter returns a random iterator for m. Each call to Next will store the next
-// key/value pair in k and v, which must be pointers. Modifying the map during
-// iteration will result in undefined behavior.
-func Iter(m, k, v interface{}) *Iterator { return randIter(m, k, v, crand.Read) }
+// key/value pair in k and v, which must be pointers. The map must not be
+// modified during iteration; see Iterator.Next.
+func Iter(m, k, v interface{}) *Iterator {
+	if probeErr != nil {
+		return &Iterator{fallback: safe.Iter(m, k, v)}
+	}
+	return randIter(m, k, v, crand.Read)
+}
 
 // FastKey returns a pseudorandom key of m, which must be a non-empty map.
-func FastKey(m interface{}) interface{} { return randKey(m, mrand.Read) }
+func FastKey(m interface{}) interface{} {
+	if probeErr != nil {
+		return safe.FastKey(m)
+	}
+	return randKey(m, fastRead)
+}
 
 // FastVal returns a pseudorandom value of m, which must be a non-empty map.
-func FastVal(m interface{}) interface{} { return randVal(m, mrand.Read) }
+func FastVal(m interface{}) interface{} {
+	if probeErr != nil {
+		return safe.FastVal(m)
+	}
+	return randVal(m, fastRead)
+}
+
+// FastEntry returns a pseudorandom key of m along with its value. m must be a
+// non-empty map.
+func FastEntry(m interface{}) (k, v interface{}) {
+	if probeErr != nil {
+		return safe.FastEntry(m)
+	}
+	return randKeyVal(m, fastRead)
+}
 
 // FastIter returns a pseudorandom iterator for m. Each call to Next will
-// store the next key/value pair in k and v, which must be pointers. Modifying
-// the map during iteration will result in undefined behavior.
-func FastIter(m, k, v interface{}) *Iterator { return randIter(m, k, v, mrand.Read) }
+// store the next key/value pair in k and v, which must be pointers. The map
+// must not be modified during iteration; see Iterator.Next.
+func FastIter(m, k, v interface{}) *Iterator {
+	if probeErr != nil {
+		return &Iterator{fallback: safe.FastIter(m, k, v)}
+	}
+	return randIter(m, k, v, fastRead)
+}
@@ -3,9 +3,13 @@ package randmap
 import (
 	"bytes"
 	"compress/gzip"
+	"math"
 	"math/rand"
 	"runtime"
+	"strconv"
 	"testing"
+
+	"github.com/lukechampine/randmap/internal/randtest"
 )
 
 // builtinInitKey selects a key by ranging over m and returning the key at the
@@ -130,6 +134,84 @@ func TestKey(t *testing.T) {
 	}
 }
 
+// checkUniform fails t if counts are implausibly far from uniform, according
+// to Pearson's chi-squared test. The bound is the Wilson-Hilferty
+// approximation of the chi-squared quantile with upper tail probability 1e-6
+// (z = 4.753); it slightly overestimates the true quantile for small df, so
+// each check fails an unbiased implementation at most once in a million runs.
+func checkUniform(t *testing.T, name string, counts []int) {
+	t.Helper()
+	if len(counts) < 2 {
+		return
+	}
+	var total int
+	for _, c := range counts {
+		total += c
+	}
+	exp := float64(total) / float64(len(counts))
+	var chi2 float64
+	for _, c := range counts {
+		d := float64(c) - exp
+		chi2 += d * d / exp
+	}
+	df := float64(len(counts) - 1)
+	const z = 4.753
+	a := 2 / (9 * df)
+	if bound := df * math.Pow(1-a+z*math.Sqrt(a), 3); chi2 > bound {
+		t.Errorf("%v: distribution is not uniform: chi-squared statistic %.1f exceeds %.1f", name, chi2, bound)
+	}
+}
+
+func TestUniform(t *testing.T) {
+	// sizes that are not powers of two, where modulo bias would show
+	for _, n := range []int{3, 7, 100} {
+		m := make(map[int]int)
+		for i := 0; i < n; i++ {
+			m[i] = i
+		}
+		iters := 200 * n
+		for _, f := range []struct {
+			name string
+			fn   func(interface{}) interface{}
+		}{
+			{"Key", Key},
+			{"FastKey", FastKey},
+			{"Val", Val},
+			{"FastVal", FastVal},
+		} {
+			counts := make([]int, n)
+			for i := 0; i < iters; i++ {
+				counts[f.fn(m).(int)]++
+			}
+			checkUniform(t, f.name+"/"+strconv.Itoa(n), counts)
+		}
+
+		// the first element of each iteration should be uniform too
+		counts := make([]int, n)
+		var k, v int
+		for i := 0; i < iters; i++ {
+			it := FastIter(m, &k, &v)
+			it.Next()
+			counts[k]++
+		}
+		checkUniform(t, "FastIter/"+strconv.Itoa(n), counts)
+	}
+
+	// a sparse map, selected by rank rather than by probing
+	m := make(map[int]int)
+	for i := 0; i < 10000; i++ {
+		m[i] = i
+	}
+	for i := 7; i < 10000; i++ {
+		delete(m, i)
+	}
+	counts := make([]int, 7)
+	for i := 0; i < 1400; i++ {
+		counts[FastKey(m).(int)]++
+	}
+	checkUniform(t, "FastKey/sparse", counts)
+}
+
 func TestVal(t *testing.T) {
 	const iters = 100000
 	m := map[int]int{
@@ -182,6 +264,35 @@ func TestFastKey(t *testing.T) {
 	}
 }
 
+func TestEntry(t *testing.T) {
+	const iters = 100000
+	m := map[int]int{
+		0: 0,
+		1: 10,
+		2: 20,
+		3: 30,
+		4: 40,
+	}
+	for name, fn := range map[string]func(interface{}) (interface{}, interface{}){
+		"Entry":     Entry,
+		"FastEntry": FastEntry,
+	} {
+		counts := make([]int, len(m))
+		for i := 0; i < iters; i++ {
+			k, v := fn(m)
+			if v.(int) != m[k.(int)] {
+				t.Fatalf("%v returned mismatched entry %v: %v", name, k, v)
+			}
+			counts[k.(int)]++
+		}
+		for n, c := range counts {
+			if (iters/len(m))/2 > c || c > (iters/len(m))*2 {
+				t.Errorf("%v: suspicious count: expected %v-%v, got %v (%v)", name, (iters/len(m))/2, (iters/len(m))*2, c, n)
+			}
+		}
+	}
+}
+
 func TestGhostIndex(t *testing.T) {
 	// sometimes, an element is never selected despite thousands of
 	// iterations. This affects the builtin map range as well.
@@ -234,6 +345,29 @@ func TestInsert(t *testing.T) {
 	}
 }
 
+func TestSparse(t *testing.T) {
+	// after a mass deletion, nearly every slot is empty. Selection should
+	// remain fast and uniform.
+	m := make(map[int]int)
+	for i := 0; i < 20000; i++ {
+		m[i] = i
+	}
+	for i := 3; i < 20000; i++ {
+		delete(m, i)
+	}
+
+	const iters = 3000
+	counts := make([]int, len(m))
+	for i := 0; i < iters; i++ {
+		counts[FastKey(m).(int)]++
+	}
+	for n, c := range counts {
+		if (iters/len(m))/2 > c || c > (iters/len(m))*2 {
+			t.Errorf("suspicious count: expected %v-%v, got %v (%v)", (iters/len(m))/2, (iters/len(m))*2, c, n)
+		}
+	}
+}
+
 func TestFastVal(t *testing.T) {
 	const iters = 100000
 	m := map[int]int{
@@ -341,6 +475,26 @@ func BenchmarkKey(b *testing.B) {
 		}
 	})
 
+	// FastKey should scale with the number of goroutines; Key is limited by
+	// the throughput of the system's entropy source
+	b.Run("key-parallel", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = Key(m).(int)
+			}
+		})
+	})
+
+	b.Run("fastkey-parallel", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = FastKey(m).(int)
+			}
+		})
+	})
+
 	b.Run("seek", func(b *testing.B) {
 		b.ReportAllocs()
 		for i := 0; i < b.N; i++ {
@@ -430,6 +584,51 @@ func TestFastIter(t *testing.T) {
 	}
 }
 
+// sizes of maps for the Iterator tests: small maps are shuffled, large maps
+// use a Feistel generator
+var iterSizes = []int{100, 10000}
+
+func fastIter(m, k, v interface{}) randtest.Iterator { return FastIter(m, k, v) }
+
+func TestIterReset(t *testing.T) {
+	for _, n := range iterSizes {
+		randtest.IterReset(t, fastIter, n)
+	}
+}
+
+func TestIterModified(t *testing.T) {
+	for _, n := range iterSizes {
+		randtest.IterModified(t, fastIter, n)
+	}
+}
+
+func TestIterDelete(t *testing.T) {
+	for _, n := range iterSizes {
+		randtest.IterDelete(t, fastIter, n)
+	}
+
+	// keys that are not equal to themselves are not removed, and are still
+	// counted (the safe backend can't look up their values)
+	if b, _ := Backend(); b == "unsafe" {
+		m := map[float64]int{math.NaN(): 0, 1: 1, 2: 2}
+		var k float64
+		var v int
+		it := FastIter(m, &k, &v)
+		for it.Next() {
+			it.Delete()
+		}
+		if len(m) != 1 {
+			t.Fatalf("expected NaN to remain in map, got %v", m)
+		} else if it.Remaining() != 0 {
+			t.Fatalf("expected 0 remaining, got %v", it.Remaining())
+		}
+		it.Reset()
+		if it.Remaining() != 1 {
+			t.Fatalf("expected 1 remaining after Reset, got %v", it.Remaining())
+		}
+	}
+}
+
 func TestIterBadType(t *testing.T) {
 	defer func() {
 		if recover() == nil {
@@ -439,6 +638,46 @@ func TestIterBadType(t *testing.T) {
 	_ = Iter(make(map[int]int), new(uint8), new(uint8))
 }
 
+func TestIterLarge(t *testing.T) {
+	// large maps are spread across many buckets (or tables); every element
+	// should be visited exactly once.
+	m := make(map[string]int)
+	for i := 0; i < 100000; i++ {
+		m[strconv.Itoa(i)] = i
+	}
+	seen := make([]bool, len(m))
+	var k string
+	var v int
+	it := FastIter(m, &k, &v)
+	n := 0
+	for ; it.Next(); n++ {
+		if k != strconv.Itoa(v) {
+			t.Fatalf("mismatched key/value pair: %q/%v", k, v)
+		} else if seen[v] {
+			t.Fatalf("visited %v twice", v)
+		}
+		seen[v] = true
+	}
+	if n != len(m) {
+		t.Fatalf("expected to visit %v elements, visited %v", len(m), n)
+	}
+}
+
+func TestPointerKeys(t *testing.T) {
+	ptrs := make([]*int, 20)
+	m := make(map[*int]int)
+	for i := range ptrs {
+		ptrs[i] = new(int)
+		m[ptrs[i]] = i
+	}
+	for i := 0; i < 1000; i++ {
+		k := FastKey(m).(*int)
+		if _, ok := m[k]; !ok {
+			t.Fatalf("Key returned a pointer that is not in the map: %p", k)
+		}
+	}
+}
+
 func BenchmarkIter(b *testing.B) {
 	m := make(map[int]int, 1000)
 	for i := 0; i < 1000; i++ {
@@ -0,0 +1,290 @@
+//go:build go1.7 && !(go1.24 && (goexperiment.swissmap || go1.26)) && !purego
+// +build go1.7
+// +build !go1.24 !goexperiment.swissmap,!go1.26
+// +build !purego
+
+package randmap
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// Before Go 1.24, maps are arrays of buckets, each holding bucketCnt
+// key/value pairs and a pointer to an overflow bucket. The definitions here
+// are shared by every release that uses buckets; anything that changed
+// between releases lives in the runtime_go1.*.go files.
+
+const (
+	// Maximum number of key/value pairs a bucket can hold.
+	bucketCntBits = 3
+	bucketCnt     = 1 << bucketCntBits
+
+	// data offset should be the size of the bmap struct, but needs to be
+	// aligned correctly. For amd64p32 this means 64-bit alignment
+	// even though pointers are 32 bit.
+	dataOffset = unsafe.Offsetof(struct {
+		b bmap
+		v int64
+	}{}.v)
+)
+
+type bmap struct {
+	tophash [bucketCnt]uint8
+	// Followed by bucketCnt keys and then bucketCnt values.
+	// NOTE: packing all the keys together and then all the values together makes the
+	// code a bit more complicated than alternating key/value/key/value/... but it allows
+	// us to eliminate padding which would be needed for, e.g., map[int64]int8.
+	// Followed by an overflow pointer.
+}
+
+func (b *bmap) overflow(t *maptype) *bmap {
+	return *(**bmap)(add(unsafe.Pointer(b), uintptr(t.bucketsize)-unsafe.Sizeof(uintptr(0))))
+}
+
+func (h *hmap) length() int {
+	return h.count
+}
+
+// hashWriting is set in hmap.flags while a goroutine is writing to the map.
+const hashWriting = 4
+
+// beingWritten reports whether a write to the map is in progress.
+func (h *hmap) beingWritten() bool {
+	return h.flags&hashWriting != 0
+}
+
+func add(p unsafe.Pointer, x uintptr) unsafe.Pointer {
+	return unsafe.Pointer(uintptr(p) + x)
+}
+
+// noPointers reports whether the buckets of t are allocated without pointers,
+// in which case overflow buckets are only kept alive by the hmap.
+func (t *maptype) noPointers() bool {
+	return t.bucket.ptrdata == 0
+}
+
+// maxOverflow returns the length of the longest bucket chain in the map.
+func maxOverflow(t *maptype, h *hmap) uint8 {
+	var max uint8
+	if h.oldbuckets != nil {
+		for i := uintptr(0); i < (1 << (h.B - 1)); i++ {
+			var over uint8
+			b := (*bmap)(add(h.oldbuckets, i*uintptr(t.bucketsize)))
+			if evacuated(b) {
+				continue
+			}
+			for b = b.overflow(t); b != nil; over++ {
+				b = b.overflow(t)
+			}
+			if over > max {
+				max = over
+			}
+		}
+	}
+	for i := uintptr(0); i < (1 << h.B); i++ {
+		var over uint8
+		for b := (*bmap)(add(h.buckets, i*uintptr(t.bucketsize))).overflow(t); b != nil; over++ {
+			b = b.overflow(t)
+		}
+		if over > max {
+			max = over
+		}
+	}
+	return max
+}
+
+// mapaccessi moves 'it' to offset 'offi' in overflow bucket 'over' of bucket
+// 'bucket' in hmap, which may or may not contain valid data. It returns true
+// if the data is valid, and false otherwise.
+func mapaccessi(t *maptype, h *hmap, it *hiter, bucket uintptr, over, offi uint8) bool {
+	// grab snapshot of bucket state. The probe's maps aren't modified while
+	// they are read, so it can skip this write to the header.
+	if !probing {
+		h.keepOverflow(t, it)
+	}
+
+	b := (*bmap)(add(h.buckets, bucket*uintptr(t.bucketsize)))
+
+	checkBucket := false
+	if h.oldbuckets != nil {
+		// Iterator was started in the middle of a grow, and the grow isn't done yet.
+		// If the bucket we're looking at hasn't been filled in yet (i.e. the old
+		// bucket hasn't been evacuated) then we need to use that pointer instead.
+		oldbucket := bucket & (uintptr(1)<<(h.B-1) - 1)
+		oldB := (*bmap)(add(h.oldbuckets, oldbucket*uintptr(t.bucketsize)))
+		if !evacuated(oldB) {
+			b = oldB
+			checkBucket = true
+		}
+	}
+
+	// seek to overflow bucket
+	for i := uint8(0); i < over; i++ {
+		b = b.overflow(t)
+		if b == nil {
+			return false
+		}
+	}
+
+	// check that bucket is not empty
+	if isEmpty(b.tophash[offi]) {
+		return false
+	}
+
+	// grab the key and value
+	k := add(unsafe.Pointer(b), dataOffset+uintptr(offi)*uintptr(t.keysize))
+	v := add(unsafe.Pointer(b), dataOffset+bucketCnt*uintptr(t.keysize)+uintptr(offi)*t.valueSize())
+	if t.isIndirectKey() {
+		k = *((*unsafe.Pointer)(k))
+	}
+	if t.isIndirectValue() {
+		v = *((*unsafe.Pointer)(v))
+	}
+
+	// if this is an old bucket, we need to check whether this key is destined
+	// for the new bucket. Otherwise, we will have a 2x bias towards oldbucket
+	// values, since two different bucket selections can result in the same
+	// oldbucket.
+	if checkBucket {
+		if t.isReflexiveKey() || t.keyEqual(k, k) {
+			// If the item in the oldbucket is not destined for
+			// the current new bucket in the iteration, skip it.
+			hash := t.hashKey(k, uintptr(h.hash0))
+			if hash&(uintptr(1)<<h.B-1) != bucket {
+				return false
+			}
+		} else {
+			// Hash isn't repeatable if k != k (NaNs).  We need a
+			// repeatable and randomish choice of which direction
+			// to send NaNs during evacuation. We'll use the low
+			// bit of tophash to decide which way NaNs go.
+			if bucket>>(h.B-1) != uintptr(b.tophash[offi]&1) {
+				return false
+			}
+		}
+	}
+
+	it.key = k
+	it.value = v
+	return true
+}
+
+// A slotSpace enumerates every slot of a map that may hold an entry. Slots
+// are numbered by bucket, then overflow bucket, then offset within the
+// bucket, so every bucket is padded out to the length of the longest chain.
+type slotSpace struct {
+	numBuckets uintptr
+	numOver    uint8
+}
+
+func newSlotSpace(t *maptype, h *hmap) slotSpace {
+	return slotSpace{
+		numBuckets: uintptr(1) << h.B,
+		numOver:    maxOverflow(t, h) + 1,
+	}
+}
+
+// size returns the number of slots in the space.
+func (s slotSpace) size() uintptr {
+	return s.numBuckets * uintptr(s.numOver) * bucketCnt
+}
+
+// A layout summarizes the shape of a map. As long as it is unchanged, so is
+// the map's slotSpace.
+type layout struct {
+	buckets    unsafe.Pointer
+	oldbuckets unsafe.Pointer
+	B          uint8
+	noverflow  uint16
+}
+
+func (h *hmap) layout() layout {
+	return layout{
+		buckets:    h.buckets,
+		oldbuckets: h.oldbuckets,
+		B:          h.B,
+		noverflow:  h.overflowCount(),
+	}
+}
+
+// stamp returns a fingerprint of the map's identity and layout. It changes
+// whenever the map's slotSpace does, and it includes the map's random hash
+// seed, so it almost certainly differs between maps.
+func (h *hmap) stamp() uint64 {
+	return fingerprint(
+		uint64(h.hash0),
+		uint64(uintptr(h.buckets)),
+		uint64(uintptr(h.oldbuckets)),
+		uint64(h.B),
+		uint64(h.overflowCount()),
+	)
+}
+
+// hashSeed returns the seed used to hash the map's keys.
+func (h *hmap) hashSeed() uintptr {
+	return uintptr(h.hash0)
+}
+
+// A placement records where a map's entries were stored when it was created.
+// Inserting and deleting entries never moves other entries, but growing the
+// map does: each write to a growing map evacuates one or two old buckets into
+// the new bucket array.
+type placement struct {
+	buckets    unsafe.Pointer
+	oldbuckets unsafe.Pointer
+	B          uint8
+	// bitset of the old buckets that had not been evacuated yet
+	unevacuated []uint64
+}
+
+func newPlacement(t *maptype, h *hmap) placement {
+	p := placement{
+		buckets:    h.buckets,
+		oldbuckets: h.oldbuckets,
+		B:          h.B,
+	}
+	if h.oldbuckets != nil {
+		n := uintptr(1) << (h.B - 1)
+		p.unevacuated = make([]uint64, (n+63)/64)
+		for i := uintptr(0); i < n; i++ {
+			if !evacuated((*bmap)(add(h.oldbuckets, i*uintptr(t.bucketsize)))) {
+				p.unevacuated[i/64] |= 1 << (i % 64)
+			}
+		}
+	}
+	return p
+}
+
+// moved reports whether the entries that slot r of s referred to when p was
+// recorded may have moved to other slots.
+func (p *placement) moved(t *maptype, h *hmap, s slotSpace, r uintptr) bool {
+	if h.buckets != p.buckets || h.oldbuckets != p.oldbuckets || h.B != p.B {
+		return true
+	} else if p.oldbuckets == nil {
+		return false
+	}
+	// the slot's entries may have been read from an old bucket that has
+	// since been evacuated
+	bucket := r / (uintptr(s.numOver) * bucketCnt)
+	old := bucket & (uintptr(1)<<(h.B-1) - 1)
+	return p.unevacuated[old/64]&(1<<(old%64)) != 0 &&
+		evacuated((*bmap)(add(h.oldbuckets, old*uintptr(t.bucketsize))))
+}
+
+// coords returns a description of the location of slot r, for debugging.
+func (s slotSpace) coords(r uintptr) string {
+	bucket := r / (uintptr(s.numOver) * bucketCnt)
+	over := (r / bucketCnt) % uintptr(s.numOver)
+	offi := r % bucketCnt
+	return fmt.Sprintf("bucket %v, overflow %v, offset %v", bucket, over, offi)
+}
+
+// access moves 'it' to slot r of the space. It returns true if the slot
+// contains valid data, and false otherwise.
+func (s slotSpace) access(t *maptype, h *hmap, it *hiter, r uintptr) bool {
+	bucket := r / (uintptr(s.numOver) * bucketCnt)
+	over := (r / bucketCnt) % uintptr(s.numOver)
+	offi := r % bucketCnt
+	return mapaccessi(t, h, it, bucket, uint8(over), uint8(offi))
+}
@@ -0,0 +1,16 @@
+//go:build go1.7 && !(go1.24 && (goexperiment.swissmap || go1.26)) && !purego
+// +build go1.7
+// +build !go1.24 !goexperiment.swissmap,!go1.26
+// +build !purego
+
+package randmap
+
+// setWriting sets or clears the flag that the runtime sets while writing to
+// the map.
+func (h *hmap) setWriting(w bool) {
+	if w {
+		h.flags |= hashWriting
+	} else {
+		h.flags &^= hashWriting
+	}
+}
@@ -0,0 +1,145 @@
+//go:build go1.10 && !go1.11 && !purego
+// +build go1.10,!go1.11,!purego
+
+package randmap
+
+import "unsafe"
+
+const (
+	// Possible tophash values. We reserve a few possibilities for special marks.
+	// Each bucket (including its overflow buckets, if any) will have either all or none of its
+	// entries in the evacuated* states (except during the evacuate() method, which only happens
+	// during map writes and thus no one else can observe the map during that time).
+	empty          = 0 // cell is empty
+	evacuatedEmpty = 1 // cell is empty, bucket is evacuated.
+	evacuatedX     = 2 // key/value is valid.  Entry has been evacuated to first half of larger table.
+	evacuatedY     = 3 // same as above, but evacuated to second half of larger table.
+	minTopHash     = 4 // minimum tophash for a normal filled cell.
+)
+
+type (
+	hmap struct {
+		count     int // # live cells == size of map.  Must be first (used by len() builtin)
+		flags     uint8
+		B         uint8  // log_2 of # of buckets (can hold up to loadFactor * 2^B items)
+		noverflow uint16 // approximate number of overflow buckets; see incrnoverflow for details
+		hash0     uint32 // hash seed
+
+		buckets    unsafe.Pointer // array of 2^B Buckets. may be nil if count==0.
+		oldbuckets unsafe.Pointer // previous bucket array of half the size, non-nil only when growing
+		nevacuate  uintptr        // progress counter for evacuation (buckets less than this have been evacuated)
+
+		extra *mapextra // optional fields
+	}
+
+	// mapextra holds fields that are not present on all maps.
+	mapextra struct {
+		// If both key and value do not contain pointers and are inline, then we mark bucket
+		// type as containing no pointers. This avoids scanning such maps.
+		// However, bmap.overflow is a pointer. In order to keep overflow buckets
+		// alive, we store pointers to all overflow buckets in hmap.extra.overflow and hmap.extra.oldoverflow.
+		// overflow and oldoverflow are only used if key and value do not contain pointers.
+		// overflow contains overflow buckets for hmap.buckets.
+		// oldoverflow contains overflow buckets for hmap.oldbuckets.
+		// The indirection allows to store a pointer to the slice in hiter.
+		overflow    *[]*bmap
+		oldoverflow *[]*bmap
+
+		// nextOverflow holds a pointer to a free overflow bucket.
+		nextOverflow *bmap
+	}
+
+	hiter struct {
+		key         unsafe.Pointer // Must be in first position.  Write nil to indicate iteration end (see cmd/internal/gc/range.go).
+		value       unsafe.Pointer // Must be in second position (see cmd/internal/gc/range.go).
+		overflow    *[]*bmap       // keeps overflow buckets of hmap.buckets alive
+		oldoverflow *[]*bmap       // keeps overflow buckets of hmap.oldbuckets alive
+	}
+
+	_type struct {
+		size       uintptr
+		ptrdata    uintptr // size of memory prefix holding all pointers
+		hash       uint32
+		tflag      uint8
+		align      uint8
+		fieldalign uint8
+		kind       uint8
+		alg        *typeAlg
+		// gcdata stores the GC type data for the garbage collector.
+		// If the KindGCProg bit is set in kind, gcdata is a GC program.
+		// Otherwise it is a ptrmask bitmap. See mbitmap.go for details.
+		gcdata    *byte
+		str       int32
+		ptrToThis int32
+	}
+
+	maptype struct {
+		typ           _type
+		key           *_type
+		elem          *_type
+		bucket        *_type // internal type representing a hash bucket
+		hmap          *_type // internal type representing a hmap
+		keysize       uint8  // size of key slot
+		indirectkey   bool   // store ptr to key instead of key itself
+		valuesize     uint8  // size of value slot
+		indirectvalue bool   // store ptr to value instead of value itself
+		bucketsize    uint16 // size of bucket
+		reflexivekey  bool   // true if k==k for all keys
+		needkeyupdate bool   // true if we need to update key on an overwrite
+	}
+
+	// typeAlg is also copied/used in reflect/type.go.
+	// keep them in sync.
+	typeAlg struct {
+		// function for hashing objects of this type
+		// (ptr to object, seed) -> hash
+		hash func(unsafe.Pointer, uintptr) uintptr
+		// function for comparing objects of this type
+		// (ptr to object A, ptr to object B) -> ==?
+		equal func(unsafe.Pointer, unsafe.Pointer) bool
+	}
+)
+
+func isEmpty(x uint8) bool {
+	return x == empty || x == evacuatedEmpty
+}
+
+func evacuated(b *bmap) bool {
+	h := b.tophash[0]
+	return h > empty && h < minTopHash
+}
+
+func (t *maptype) isIndirectKey() bool   { return t.indirectkey }
+func (t *maptype) isIndirectValue() bool { return t.indirectvalue }
+func (t *maptype) isReflexiveKey() bool  { return t.reflexivekey }
+func (t *maptype) valueSize() uintptr    { return uintptr(t.valuesize) }
+
+func (t *maptype) hashKey(k unsafe.Pointer, seed uintptr) uintptr { return t.key.alg.hash(k, seed) }
+func (t *maptype) keyEqual(a, b unsafe.Pointer) bool              { return t.key.alg.equal(a, b) }
+
+// overflowCount returns the approximate number of overflow buckets in h.
+func (h *hmap) overflowCount() uint16 {
+	return h.noverflow
+}
+
+// keepOverflow stores references to the overflow buckets of h in it.
+func (h *hmap) keepOverflow(t *maptype, it *hiter) {
+	if t.noPointers() {
+		// Allocate the current slice and remember pointers to both current and old.
+		// This preserves all relevant overflow buckets alive even if
+		// the table grows and/or overflow buckets are added to the table
+		// while we are iterating.
+		h.createOverflow()
+		it.overflow = h.extra.overflow
+		it.oldoverflow = h.extra.oldoverflow
+	}
+}
+
+func (h *hmap) createOverflow() {
+	if h.extra == nil {
+		h.extra = new(mapextra)
+	}
+	if h.extra.overflow == nil {
+		h.extra.overflow = new([]*bmap)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build go1.24 && !go1.27 && (goexperiment.swissmap || go1.26) && !purego
+// +build go1.24
+// +build !go1.27
+// +build goexperiment.swissmap go1.26
+// +build !purego
+
+package randmap
+
+import "unsafe"
+
+// offset of the slots array in a group; the slots follow a single word of
+// control bytes.
+const groupSlotsOffset = unsafe.Sizeof(uint64(0))
+
+type maptype struct {
+	typ   _type
+	key   *_type
+	elem  *_type
+	group *_type // internal type representing a slot group
+	// function for hashing keys (ptr to key, seed) -> hash
+	hasher    func(unsafe.Pointer, uintptr) uintptr
+	groupSize uintptr // == group.size
+	slotSize  uintptr // size of key/elem slot
+	elemOff   uintptr // offset of elem in key/elem slot
+	flags     uint32
+}
+
+func (t *maptype) keyAt(g unsafe.Pointer, i uintptr) unsafe.Pointer {
+	return add(g, groupSlotsOffset+i*t.slotSize)
+}
+
+func (t *maptype) elemAt(g unsafe.Pointer, i uintptr) unsafe.Pointer {
+	return add(g, groupSlotsOffset+i*t.slotSize+t.elemOff)
+}
@@ -0,0 +1,33 @@
+//go:build go1.27 && !purego
+// +build go1.27,!purego
+
+package randmap
+
+import "unsafe"
+
+type maptype struct {
+	typ   _type
+	key   *_type
+	elem  *_type
+	group *_type // internal type representing a slot group
+	// function for hashing keys (ptr to key, seed) -> hash
+	hasher    func(unsafe.Pointer, uintptr) uintptr
+	groupSize uintptr // == group.size
+	// These fields describe how to access keys and elems within a group.
+	// They work for both the interleaved (KVKVKVKV) and split (KKKKVVVV)
+	// group layouts.
+	keysOff    uintptr
+	keyStride  uintptr
+	elemsOff   uintptr
+	elemStride uintptr
+	elemOff    uintptr // GOEXPERIMENT=nomapsplitgroup only
+	flags      uint32
+}
+
+func (t *maptype) keyAt(g unsafe.Pointer, i uintptr) unsafe.Pointer {
+	return add(g, t.keysOff+i*t.keyStride)
+}
+
+func (t *maptype) elemAt(g unsafe.Pointer, i uintptr) unsafe.Pointer {
+	return add(g, t.elemsOff+i*t.elemStride)
+}
@@ -1,22 +1,11 @@
-// +build go1.7
+//go:build go1.7 && !go1.8 && !purego
+// +build go1.7,!go1.8,!purego
 
 package randmap
 
 import "unsafe"
 
 const (
-	// Maximum number of key/value pairs a bucket can hold.
-	bucketCntBits = 3
-	bucketCnt     = 1 << bucketCntBits
-
-	// data offset should be the size of the bmap struct, but needs to be
-	// aligned correctly. For amd64p32 this means 64-bit alignment
-	// even though pointers are 32 bit.
-	dataOffset = unsafe.Offsetof(struct {
-		b bmap
-		v int64
-	}{}.v)
-
 	// Possible tophash values. We reserve a few possibilities for special marks.
 	// Each bucket (including its overflow buckets, if any) will have either all or none of its
 	// entries in the evacuated* states (except during the evacuate() method, which only happens
@@ -26,10 +15,6 @@ const (
 	evacuatedX     = 2 // key/value is valid.  Entry has been evacuated to first half of larger table.
 	evacuatedY     = 3 // same as above, but evacuated to second half of larger table.
 	minTopHash     = 4 // minimum tophash for a normal filled cell.
-
-	noCheck = 1<<(8*unsafe.Sizeof(uintptr(0))) - 1
-
-	kindNoPointers = 1 << 7
 )
 
 type (
@@ -55,15 +40,6 @@ type (
 		overflow *[2]*[]*bmap
 	}
 
-	bmap struct {
-		tophash [bucketCnt]uint8
-		// Followed by bucketCnt keys and then bucketCnt values.
-		// NOTE: packing all the keys together and then all the values together makes the
-		// code a bit more complicated than alternating key/value/key/value/... but it allows
-		// us to eliminate padding which would be needed for, e.g., map[int64]int8.
-		// Followed by an overflow pointer.
-	}
-
 	hiter struct {
 		key      unsafe.Pointer // Must be in first position.  Write nil to indicate iteration end (see cmd/internal/gc/range.go).
 		value    unsafe.Pointer // Must be in second position (see cmd/internal/gc/range.go).
@@ -82,7 +58,7 @@ type (
 		// gcdata stores the GC type data for the garbage collector.
 		// If the KindGCProg bit is set in kind, gcdata is a GC program.
 		// Otherwise it is a ptrmask bitmap. See mbitmap.go for details.
-		gcdata    uintptr
+		gcdata    *byte
 		str       int32
 		ptrToThis int32
 	}
@@ -114,64 +90,32 @@ type (
 	}
 )
 
+func isEmpty(x uint8) bool {
+	return x == empty || x == evacuatedEmpty
+}
+
 func evacuated(b *bmap) bool {
 	h := b.tophash[0]
 	return h > empty && h < minTopHash
 }
 
-func (b *bmap) overflow(t *maptype) *bmap {
-	return *(**bmap)(add(unsafe.Pointer(b), uintptr(t.bucketsize)-unsafe.Sizeof(uintptr(0))))
-}
+func (t *maptype) isIndirectKey() bool   { return t.indirectkey }
+func (t *maptype) isIndirectValue() bool { return t.indirectvalue }
+func (t *maptype) isReflexiveKey() bool  { return t.reflexivekey }
+func (t *maptype) valueSize() uintptr    { return uintptr(t.valuesize) }
 
-func (h *hmap) createOverflow() {
-	if h.overflow == nil {
-		h.overflow = new([2]*[]*bmap)
-	}
-	if h.overflow[0] == nil {
-		h.overflow[0] = new([]*bmap)
-	}
-}
+func (t *maptype) hashKey(k unsafe.Pointer, seed uintptr) uintptr { return t.key.alg.hash(k, seed) }
+func (t *maptype) keyEqual(a, b unsafe.Pointer) bool              { return t.key.alg.equal(a, b) }
 
-func add(p unsafe.Pointer, x uintptr) unsafe.Pointer {
-	return unsafe.Pointer(uintptr(p) + x)
+// overflowCount returns the approximate number of overflow buckets in h. Go
+// 1.7 does not track this, so changes to h.count must suffice.
+func (h *hmap) overflowCount() uint16 {
+	return 0
 }
 
-// maxOverflow returns the length of the longest bucket chain in the map.
-func maxOverflow(t *maptype, h *hmap) uint8 {
-	var max uint8
-	if h.oldbuckets != nil {
-		for i := uintptr(0); i < (1 << (h.B - 1)); i++ {
-			var over uint8
-			b := (*bmap)(add(h.oldbuckets, i*uintptr(t.bucketsize)))
-			if evacuated(b) {
-				continue
-			}
-			for b = b.overflow(t); b != nil; over++ {
-				b = b.overflow(t)
-			}
-			if over > max {
-				max = over
-			}
-		}
-	}
-	for i := uintptr(0); i < (1 << h.B); i++ {
-		var over uint8
-		for b := (*bmap)(add(h.buckets, i*uintptr(t.bucketsize))).overflow(t); b != nil; over++ {
-			b = b.overflow(t)
-		}
-		if over > max {
-			max = over
-		}
-	}
-	return max
-}
-
-// mapaccessi moves 'it' to offset 'offi' in overflow bucket 'over' of bucket
-// 'bucket' in hmap, which may or may not contain valid data. It returns true
-// if the data is valid, and false otherwise.
-func mapaccessi(t *maptype, h *hmap, it *hiter, bucket uintptr, over, offi uint8) bool {
-	// grab snapshot of bucket state
-	if t.bucket.kind&kindNoPointers != 0 {
+// keepOverflow stores references to the overflow buckets of h in it.
+func (h *hmap) keepOverflow(t *maptype, it *hiter) {
+	if t.noPointers() {
 		// Allocate the current slice and remember pointers to both current and old.
 		// This preserves all relevant overflow buckets alive even if
 		// the table grows and/or overflow buckets are added to the table
@@ -179,69 +123,13 @@ func mapaccessi(t *maptype, h *hmap, it *hiter, bucket uintptr, over, offi uint8
 		h.createOverflow()
 		it.overflow = *h.overflow
 	}
+}
 
-	b := (*bmap)(add(h.buckets, bucket*uintptr(t.bucketsize)))
-
-	checkBucket := false
-	if h.oldbuckets != nil {
-		// Iterator was started in the middle of a grow, and the grow isn't done yet.
-		// If the bucket we're looking at hasn't been filled in yet (i.e. the old
-		// bucket hasn't been evacuated) then we need to use that pointer instead.
-		oldbucket := bucket & (uintptr(1)<<(h.B-1) - 1)
-		oldB := (*bmap)(add(h.oldbuckets, oldbucket*uintptr(t.bucketsize)))
-		if !evacuated(oldB) {
-			b = oldB
-			checkBucket = true
-		}
-	}
-
-	// seek to overflow bucket
-	for i := uint8(0); i < over; i++ {
-		b = b.overflow(t)
-		if b == nil {
-			return false
-		}
-	}
-
-	// check that bucket is not empty
-	if b.tophash[offi] == empty || b.tophash[offi] == evacuatedEmpty {
-		return false
-	}
-
-	// grab the key and value
-	k := add(unsafe.Pointer(b), dataOffset+uintptr(offi)*uintptr(t.keysize))
-	v := add(unsafe.Pointer(b), dataOffset+bucketCnt*uintptr(t.keysize)+uintptr(offi)*uintptr(t.valuesize))
-	if t.indirectkey {
-		k = *((*unsafe.Pointer)(k))
-	}
-	if t.indirectvalue {
-		v = *((*unsafe.Pointer)(v))
+func (h *hmap) createOverflow() {
+	if h.overflow == nil {
+		h.overflow = new([2]*[]*bmap)
 	}
-
-	// if this is an old bucket, we need to check whether this key is destined
-	// for the new bucket. Otherwise, we will have a 2x bias towards oldbucket
-	// values, since two different bucket selections can result in the same
-	// oldbucket.
-	if checkBucket {
-		if t.reflexivekey || t.key.alg.equal(k, k) {
-			// If the item in the oldbucket is not destined for
-			// the current new bucket in the iteration, skip it.
-			hash := t.key.alg.hash(k, uintptr(h.hash0))
-			if hash&(uintptr(1)<<h.B-1) != bucket {
-				return false
-			}
-		} else {
-			// Hash isn't repeatable if k != k (NaNs).  We need a
-			// repeatable and randomish choice of which direction
-			// to send NaNs during evacuation. We'll use the low
-			// bit of tophash to decide which way NaNs go.
-			if bucket>>(h.B-1) != uintptr(b.tophash[offi]&1) {
-				return false
-			}
-		}
+	if h.overflow[0] == nil {
+		h.overflow[0] = new([]*bmap)
 	}
-
-	it.key = k
-	it.value = v
-	return true
 }
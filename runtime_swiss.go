@@ -0,0 +1,328 @@
+//go:build go1.24 && (goexperiment.swissmap || go1.26) && !purego
+// +build go1.24
+// +build goexperiment.swissmap go1.26
+// +build !purego
+
+package randmap
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// Starting with Go 1.24, maps are Swiss tables. A map is a directory of
+// tables, each table is an array of groups, and each group holds groupSlots
+// key/elem slots alongside a word of control bytes. Small maps skip the
+// directory entirely and point straight at a single group.
+//
+// Unlike the bucket implementation, tables grow all at once rather than
+// incrementally, so there is no "old" data to take into account.
+
+const (
+	// Number of slots in a group.
+	groupSlotsBits = 3
+	groupSlots     = 1 << groupSlotsBits
+
+	// A control byte with its high bit set is either empty or deleted; a
+	// full slot stores the low 7 bits of the key's hash.
+	ctrlEmpty = 0x80
+
+	// Maximum number of slots in a table.
+	maxTableCapacity = 1024
+
+	// maptype flags.
+	mapNeedKeyUpdate  = 1 << 0
+	mapHashMightPanic = 1 << 1
+	mapIndirectKey    = 1 << 2
+	mapIndirectElem   = 1 << 3
+)
+
+type (
+	hmap struct {
+		// The number of filled slots (i.e. the number of elements in all
+		// tables). Excludes deleted slots.
+		// Must be first (known by the compiler, for len() builtin).
+		used uint64
+
+		// seed is the hash seed, computed as a unique random number per map.
+		seed uintptr
+
+		// The directory of tables.
+		//
+		// Normally dirPtr points to an array of table pointers
+		//
+		// dirPtr *[dirLen]*table
+		//
+		// The length (dirLen) of this array is `1 << globalDepth`. Multiple
+		// entries may point to the same table.
+		//
+		// Small map optimization: if the map always contained
+		// groupSlots or fewer entries, it fits entirely in a
+		// single group. In that case dirPtr points directly to a single group.
+		//
+		// dirPtr *group
+		//
+		// In this case, dirLen is 0. used counts the number of used slots in
+		// the group.
+		dirPtr unsafe.Pointer
+		dirLen int
+
+		// The number of bits to use in table directory lookups.
+		globalDepth uint8
+
+		// The number of bits to shift out of the hash for directory lookups.
+		globalShift uint8
+
+		// writing is a flag that is toggled (XOR 1) while the map is being
+		// written.
+		writing uint8
+
+		// tombstonePossible is false if we know that no table in this map
+		// contains a tombstone.
+		tombstonePossible bool
+
+		// clearSeq is a sequence counter of calls to Clear.
+		clearSeq uint64
+	}
+
+	table struct {
+		// The number of filled slots (i.e. the number of elements in the table).
+		used uint16
+
+		// The total number of slots (always 2^N). Equal to
+		// `(groups.lengthMask+1)*groupSlots`.
+		capacity uint16
+
+		// The number of slots we can still fill without needing to rehash.
+		growthLeft uint16
+
+		// The number of bits used by directory lookups above this table.
+		localDepth uint8
+
+		// Index of this table in the Map directory. This is the index of the
+		// _first_ location in the directory. The table may occur in multiple
+		// sequential indices.
+		//
+		// index is -1 if the table is stale (no longer installed in the
+		// directory).
+		index int
+
+		// groups is an array of slot groups.
+		groups groupsReference
+	}
+
+	groupsReference struct {
+		// data points to an array of groups.
+		data unsafe.Pointer // data *[length]typ.Group
+
+		// lengthMask is the number of groups in data minus one (note that
+		// length must be a power of two). This allows computing i%length
+		// quickly using bitwise AND.
+		lengthMask uint64
+	}
+
+	hiter struct {
+		key   unsafe.Pointer
+		value unsafe.Pointer
+	}
+
+	_type struct {
+		size       uintptr
+		ptrdata    uintptr // size of memory prefix holding all pointers
+		hash       uint32
+		tflag      uint8
+		align      uint8
+		fieldalign uint8
+		kind       uint8
+		// function for comparing objects of this type
+		// (ptr to object A, ptr to object B) -> ==?
+		equal func(unsafe.Pointer, unsafe.Pointer) bool
+		// gcdata stores the GC type data for the garbage collector.
+		gcdata    *byte
+		str       int32
+		ptrToThis int32
+	}
+)
+
+func (t *maptype) indirectKey() bool {
+	return t.flags&mapIndirectKey != 0
+}
+
+func (t *maptype) indirectElem() bool {
+	return t.flags&mapIndirectElem != 0
+}
+
+func (t *maptype) hashKey(k unsafe.Pointer, seed uintptr) uintptr {
+	return t.hasher(k, seed)
+}
+
+func (h *hmap) length() int {
+	return int(h.used)
+}
+
+// beingWritten reports whether a write to the map is in progress.
+func (h *hmap) beingWritten() bool {
+	return h.writing != 0
+}
+
+func (h *hmap) directoryAt(i uintptr) *table {
+	return *(**table)(add(h.dirPtr, ptrSize*i))
+}
+
+// ctrl returns the control byte for slot i of group g.
+func ctrl(g unsafe.Pointer, i uintptr) uint8 {
+	// reading the control bytes as a word makes this endian-agnostic
+	return uint8(*(*uint64)(g) >> (8 * i))
+}
+
+func add(p unsafe.Pointer, x uintptr) unsafe.Pointer {
+	return unsafe.Pointer(uintptr(p) + x)
+}
+
+// A slotSpace enumerates every slot of a map that may hold an entry. Slots
+// are numbered by directory index, then slot within the table. Every
+// directory entry is padded out to the capacity of the largest table, and
+// only the first directory entry of each table is considered, so that no
+// slot can be reached twice.
+type slotSpace struct {
+	dirLen   uintptr
+	tableCap uintptr
+}
+
+func newSlotSpace(t *maptype, h *hmap) slotSpace {
+	switch h.dirLen {
+	case 0:
+		return slotSpace{dirLen: 1, tableCap: groupSlots}
+	case 1:
+		return slotSpace{dirLen: 1, tableCap: uintptr(h.directoryAt(0).capacity)}
+	default:
+		// Tables split rather than growing beyond maxTableCapacity, so
+		// there's no need to scan the directory for the largest table.
+		// (Nearly every table of a map this large is at capacity anyway.)
+		return slotSpace{dirLen: uintptr(h.dirLen), tableCap: maxTableCapacity}
+	}
+}
+
+// A layout summarizes the shape of a map. As long as it is unchanged, so is
+// the map's slotSpace.
+type layout struct {
+	dirPtr unsafe.Pointer
+	dirLen int
+	table  *table // only set if dirLen == 1
+}
+
+func (h *hmap) layout() layout {
+	l := layout{dirPtr: h.dirPtr, dirLen: h.dirLen}
+	if h.dirLen == 1 {
+		l.table = h.directoryAt(0)
+	}
+	return l
+}
+
+// stamp returns a fingerprint of the map's identity and layout. It changes
+// whenever any of the map's tables are grown or split, and it includes the
+// map's random hash seed, so it almost certainly differs between maps.
+func (h *hmap) stamp() uint64 {
+	x := fingerprint(uint64(h.seed), uint64(uintptr(h.dirPtr)), uint64(h.dirLen))
+	// Growing or splitting a table replaces it in the directory. A table
+	// occupies a contiguous run of directory entries, so only the first entry
+	// of each run needs to be mixed in.
+	var prev *table
+	for i := 0; i < h.dirLen; i++ {
+		if t := h.directoryAt(uintptr(i)); t != prev {
+			x = (x ^ uint64(uintptr(unsafe.Pointer(t)))) * 1099511628211
+			prev = t
+		}
+	}
+	return x
+}
+
+// hashSeed returns the seed used to hash the map's keys.
+func (h *hmap) hashSeed() uintptr {
+	return h.seed
+}
+
+// A placement records where a map's entries were stored when it was created.
+// Inserting and deleting entries never moves other entries, but growing or
+// splitting a table does: the table's entries are rehashed into new tables,
+// which replace it in the directory.
+type placement struct {
+	dirPtr unsafe.Pointer
+	dirLen int
+	tables []*table
+}
+
+func newPlacement(t *maptype, h *hmap) placement {
+	p := placement{dirPtr: h.dirPtr, dirLen: h.dirLen}
+	for i := 0; i < h.dirLen; i++ {
+		p.tables = append(p.tables, h.directoryAt(uintptr(i)))
+	}
+	return p
+}
+
+// moved reports whether the entries that slot r of s referred to when p was
+// recorded may have moved to other slots.
+func (p *placement) moved(t *maptype, h *hmap, s slotSpace, r uintptr) bool {
+	if h.dirPtr != p.dirPtr || h.dirLen != p.dirLen {
+		return true
+	} else if h.dirLen == 0 {
+		return false
+	}
+	// A table's entries can only move when it is replaced, and its slots
+	// are only reachable through its first directory entry, so checking
+	// that entry suffices.
+	dir := r / s.tableCap
+	return dir < uintptr(h.dirLen) && h.directoryAt(dir) != p.tables[dir]
+}
+
+// size returns the number of slots in the space.
+func (s slotSpace) size() uintptr {
+	return s.dirLen * s.tableCap
+}
+
+// coords returns a description of the location of slot r, for debugging.
+func (s slotSpace) coords(r uintptr) string {
+	dir, slot := r/s.tableCap, r%s.tableCap
+	return fmt.Sprintf("directory %v, group %v, slot %v", dir, slot/groupSlots, slot%groupSlots)
+}
+
+// access moves 'it' to slot r of the space. It returns true if the slot
+// contains valid data, and false otherwise.
+func (s slotSpace) access(t *maptype, h *hmap, it *hiter, r uintptr) bool {
+	dir, slot := r/s.tableCap, r%s.tableCap
+	var g unsafe.Pointer
+	if h.dirLen == 0 {
+		if dir != 0 || slot >= groupSlots || h.dirPtr == nil {
+			return false
+		}
+		g = h.dirPtr
+	} else {
+		if dir >= uintptr(h.dirLen) {
+			return false
+		}
+		tab := h.directoryAt(dir)
+		if tab.index != int(dir) || slot >= uintptr(tab.capacity) {
+			return false
+		}
+		g = add(tab.groups.data, (slot/groupSlots)*t.groupSize)
+	}
+	return groupaccessi(t, g, it, slot%groupSlots)
+}
+
+// groupaccessi moves 'it' to slot i of group g, which may or may not contain
+// valid data. It returns true if the data is valid, and false otherwise.
+func groupaccessi(t *maptype, g unsafe.Pointer, it *hiter, i uintptr) bool {
+	if ctrl(g, i)&ctrlEmpty != 0 {
+		return false
+	}
+	k, v := t.keyAt(g, i), t.elemAt(g, i)
+	if t.indirectKey() {
+		k = *((*unsafe.Pointer)(k))
+	}
+	if t.indirectElem() {
+		v = *((*unsafe.Pointer)(v))
+	}
+	it.key = k
+	it.value = v
+	return true
+}
@@ -0,0 +1,51 @@
+package randmap
+
+import "reflect"
+
+func streamKeys(m interface{}, n int, Intn randIntn, fn func(k interface{}) bool) {
+	if n <= 0 {
+		return
+	}
+	keys := reflect.ValueOf(m).MapKeys()
+	for i := 0; i < n; i++ {
+		if !fn(keys[Intn(len(keys))].Interface()) {
+			return
+		}
+	}
+}
+
+func appendKeys(dst []interface{}, m interface{}, n int, Intn randIntn) []interface{} {
+	streamKeys(m, n, Intn, func(k interface{}) bool {
+		dst = append(dst, k)
+		return true
+	})
+	return dst
+}
+
+// KeysWithReplacement appends n uniform random keys of m, which must be a
+// non-empty map, to dst and returns the extended slice. The same key may be
+// selected more than once.
+func KeysWithReplacement(m interface{}, n int, dst []interface{}) []interface{} {
+	return appendKeys(dst, m, n, cRandInt)
+}
+
+// KeysWithReplacementFunc calls fn on n uniform random keys of m, which must
+// be a non-empty map, stopping early if fn returns false. The same key may be
+// selected more than once.
+func KeysWithReplacementFunc(m interface{}, n int, fn func(k interface{}) bool) {
+	streamKeys(m, n, cRandInt, fn)
+}
+
+// FastKeysWithReplacement appends n pseudorandom keys of m, which must be a
+// non-empty map, to dst and returns the extended slice. The same key may be
+// selected more than once.
+func FastKeysWithReplacement(m interface{}, n int, dst []interface{}) []interface{} {
+	return appendKeys(dst, m, n, mRandInt)
+}
+
+// FastKeysWithReplacementFunc calls fn on n pseudorandom keys of m, which
+// must be a non-empty map, stopping early if fn returns false. The same key
+// may be selected more than once.
+func FastKeysWithReplacementFunc(m interface{}, n int, fn func(k interface{}) bool) {
+	streamKeys(m, n, mRandInt, fn)
+}
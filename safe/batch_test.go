@@ -0,0 +1,30 @@
+package randmap
+
+import "testing"
+
+func TestKeysWithReplacement(t *testing.T) {
+	m := map[int]int{0: 0, 1: 1, 2: 2, 3: 3, 4: 4}
+	const iters = 100000
+	keys := FastKeysWithReplacement(m, iters, []interface{}{-1})
+	if len(keys) != iters+1 || keys[0] != -1 {
+		t.Fatal("FastKeysWithReplacement did not append to dst")
+	}
+	counts := make([]int, len(m))
+	for _, k := range keys[1:] {
+		counts[k.(int)]++
+	}
+	for n, c := range counts {
+		if (iters/len(m))/2 > c || c > (iters/len(m))*2 {
+			t.Errorf("suspicious count: expected %v-%v, got %v (%v)", (iters/len(m))/2, (iters/len(m))*2, c, n)
+		}
+	}
+
+	var n int
+	KeysWithReplacementFunc(m, 1<<40, func(k interface{}) bool {
+		n++
+		return n < 10
+	})
+	if n != 10 {
+		t.Fatal("expected early stop after 10 keys, got", n)
+	}
+}
@@ -0,0 +1,13 @@
+package randmap
+
+import "errors"
+
+var errCheckpoint = errors.New("randmap: the safe backend cannot checkpoint iterators")
+
+// MarshalBinary implements encoding.BinaryMarshaler. The safe backend cannot
+// checkpoint iterators, so it always returns an error.
+func (i *Iterator) MarshalBinary() ([]byte, error) { return nil, errCheckpoint }
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. The safe backend
+// cannot checkpoint iterators, so it always returns an error.
+func (i *Iterator) UnmarshalBinary(b []byte) error { return errCheckpoint }
@@ -0,0 +1,29 @@
+//go:build !go1.22
+// +build !go1.22
+
+package randmap
+
+import (
+	"encoding/binary"
+	"sync"
+
+	crand "crypto/rand"
+	mrand "math/rand"
+)
+
+// fastPool holds independently-seeded generators, so that concurrent callers
+// of mRandInt do not contend on the global math/rand lock.
+var fastPool = sync.Pool{
+	New: func() interface{} {
+		var seed [8]byte
+		crand.Read(seed[:])
+		return mrand.New(mrand.NewSource(int64(binary.LittleEndian.Uint64(seed[:]))))
+	},
+}
+
+func mRandInt(n int) int {
+	r := fastPool.Get().(*mrand.Rand)
+	i := r.Intn(n)
+	fastPool.Put(r)
+	return i
+}
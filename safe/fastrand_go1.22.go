@@ -0,0 +1,10 @@
+//go:build go1.22
+// +build go1.22
+
+package randmap
+
+import "math/rand/v2"
+
+// mRandInt uses the runtime's per-thread ChaCha8 generator, which the
+// top-level math/rand/v2 functions access without locking.
+func mRandInt(n int) int { return rand.IntN(n) }
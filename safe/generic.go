@@ -0,0 +1,131 @@
+//go:build go1.18
+// +build go1.18
+
+package randmap
+
+// The functions in this file are type-safe equivalents of Key, Val, Iter, and
+// Pop.
+
+func randEntryOf[K comparable, V any](m map[K]V, Intn randIntn) (k K, v V) {
+	r := Intn(len(m))
+	for k, v = range m {
+		if r--; r < 0 {
+			return
+		}
+	}
+	panic("empty map")
+}
+
+func randPopEntry[K comparable, V any](m map[K]V, Intn randIntn) (K, V) {
+	k, v := randEntryOf(m, Intn)
+	delete(m, k)
+	return k, v
+}
+
+func randKeyOf[K comparable, V any](m map[K]V, Intn randIntn) K {
+	k, _ := randEntryOf(m, Intn)
+	return k
+}
+
+func randValOf[K comparable, V any](m map[K]V, Intn randIntn) V {
+	_, v := randEntryOf(m, Intn)
+	return v
+}
+
+// A TypedIterator iterates over a map in random or pseudorandom order. It is
+// intended to be used in a for loop like so:
+//
+//	m := make(map[int]int)
+//	i := IterOf(m)
+//	for i.Next() {
+//	    // use i.Key() and i.Val()
+//	}
+type TypedIterator[K comparable, V any] struct {
+	// map, stored so that we can lookup values via keys
+	m map[K]V
+	// random permutation of map keys, resliced each time we iterate, and the
+	// length of the map when the permutation was generated
+	perm []K
+	n    int
+	// current key and value
+	k K
+	v V
+}
+
+// Next advances the TypedIterator to the next element in the map. It returns
+// false when all of the elements have been enumerated. Like Iterator.Next, it
+// panics if the map has been modified during iteration.
+func (i *TypedIterator[K, V]) Next() bool {
+	if i == nil || len(i.perm) == 0 {
+		return false
+	} else if len(i.m) != i.n {
+		panic("randmap: map modified during iteration")
+	}
+	i.k, i.perm = i.perm[0], i.perm[1:]
+	i.v = i.m[i.k]
+	return true
+}
+
+// Key returns the key of the current element.
+func (i *TypedIterator[K, V]) Key() K { return i.k }
+
+// Val returns the value of the current element.
+func (i *TypedIterator[K, V]) Val() V { return i.v }
+
+func randIterOf[K comparable, V any](m map[K]V, Intn randIntn) *TypedIterator[K, V] {
+	// create a random permutation of m's keys
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	for i := len(keys) - 1; i >= 1; i-- {
+		j := Intn(i + 1)
+		keys[i], keys[j] = keys[j], keys[i]
+	}
+
+	return &TypedIterator[K, V]{
+		m:    m,
+		perm: keys,
+		n:    len(m),
+	}
+}
+
+// KeyOf returns a uniform random key of m, which must be a non-empty map.
+func KeyOf[K comparable, V any](m map[K]V) K { return randKeyOf(m, cRandInt) }
+
+// ValOf returns a uniform random value of m, which must be a non-empty map.
+func ValOf[K comparable, V any](m map[K]V) V { return randValOf(m, cRandInt) }
+
+// EntryOf returns a uniform random key of m, which must be a non-empty map,
+// along with its associated value.
+func EntryOf[K comparable, V any](m map[K]V) (K, V) { return randEntryOf(m, cRandInt) }
+
+// PopEntry removes a uniform random entry from m, which must be a non-empty
+// map, and returns its key and value. It is the type-safe equivalent of Pop.
+// As with the builtin delete, an entry whose key is not equal to itself (such
+// as NaN) is returned but not removed.
+func PopEntry[K comparable, V any](m map[K]V) (K, V) { return randPopEntry(m, cRandInt) }
+
+// IterOf returns a random iterator for m. Next panics if the map gains or
+// loses entries during iteration; see Iterator.Next.
+func IterOf[K comparable, V any](m map[K]V) *TypedIterator[K, V] { return randIterOf(m, cRandInt) }
+
+// FastKeyOf returns a pseudorandom key of m, which must be a non-empty map.
+func FastKeyOf[K comparable, V any](m map[K]V) K { return randKeyOf(m, mRandInt) }
+
+// FastValOf returns a pseudorandom value of m, which must be a non-empty map.
+func FastValOf[K comparable, V any](m map[K]V) V { return randValOf(m, mRandInt) }
+
+// FastEntryOf returns a pseudorandom key of m, which must be a non-empty map,
+// along with its associated value.
+func FastEntryOf[K comparable, V any](m map[K]V) (K, V) { return randEntryOf(m, mRandInt) }
+
+// FastPopEntry removes a pseudorandom entry from m, which must be a non-empty
+// map, and returns its key and value. It is the type-safe equivalent of
+// FastPop. As with the builtin delete, an entry whose key is not equal to
+// itself (such as NaN) is returned but not removed.
+func FastPopEntry[K comparable, V any](m map[K]V) (K, V) { return randPopEntry(m, mRandInt) }
+
+// FastIterOf returns a pseudorandom iterator for m. Next panics if the map
+// gains or loses entries during iteration; see Iterator.Next.
+func FastIterOf[K comparable, V any](m map[K]V) *TypedIterator[K, V] { return randIterOf(m, mRandInt) }
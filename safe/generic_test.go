@@ -0,0 +1,30 @@
+//go:build go1.18
+// +build go1.18
+
+package randmap
+
+import (
+	"testing"
+
+	"github.com/lukechampine/randmap/internal/randtest"
+)
+
+func TestKeyOf(t *testing.T) {
+	randtest.KeyOf(t, KeyOf[int, int])
+	randtest.KeyOf(t, FastKeyOf[int, int])
+}
+
+func TestEntryOf(t *testing.T) {
+	randtest.EntryOf(t, EntryOf[string, []int], ValOf[string, []int])
+	randtest.EntryOf(t, FastEntryOf[string, []int], FastValOf[string, []int])
+}
+
+func TestPopEntry(t *testing.T) {
+	randtest.PopEntry(t, PopEntry[string, []int])
+	randtest.PopEntry(t, FastPopEntry[string, []int])
+}
+
+func TestIterOf(t *testing.T) {
+	randtest.IterOf(t, func(m map[int]int) randtest.TypedIterator { return IterOf(m) })
+	randtest.IterOf(t, func(m map[int]int) randtest.TypedIterator { return FastIterOf(m) })
+}
@@ -0,0 +1,22 @@
+package randmap
+
+import "reflect"
+
+func randPop(m interface{}, Intn randIntn) (k, v interface{}) {
+	mv := reflect.ValueOf(m)
+	keys := mv.MapKeys()
+	key := keys[Intn(len(keys))]
+	v = mv.MapIndex(key).Interface()
+	mv.SetMapIndex(key, reflect.Value{})
+	return key.Interface(), v
+}
+
+// Pop removes a uniform random entry from m, which must be a non-empty map,
+// and returns its key and value. As with the builtin delete, an entry whose
+// key is not equal to itself (such as NaN) is returned but not removed.
+func Pop(m interface{}) (k, v interface{}) { return randPop(m, cRandInt) }
+
+// FastPop removes a pseudorandom entry from m, which must be a non-empty map,
+// and returns its key and value. As with the builtin delete, an entry whose
+// key is not equal to itself (such as NaN) is returned but not removed.
+func FastPop(m interface{}) (k, v interface{}) { return randPop(m, mRandInt) }
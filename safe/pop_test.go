@@ -0,0 +1,26 @@
+package randmap
+
+import "testing"
+
+func TestPop(t *testing.T) {
+	m := make(map[int]int)
+	for i := 0; i < 100; i++ {
+		m[i] = i * 10
+	}
+	seen := make(map[int]bool)
+	for len(m) > 0 {
+		n := len(m)
+		k, v := FastPop(m)
+		if len(m) != n-1 || seen[k.(int)] {
+			t.Fatalf("Pop did not remove %v", k)
+		} else if v.(int) != k.(int)*10 {
+			t.Fatalf("Pop returned mismatched entry %v: %v", k, v)
+		}
+		seen[k.(int)] = true
+	}
+
+	im := map[interface{}]int{nil: 0}
+	if k, _ := Pop(im); k != nil || len(im) != 0 {
+		t.Fatal("failed to pop nil key")
+	}
+}
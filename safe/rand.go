@@ -0,0 +1,66 @@
+package randmap
+
+import (
+	"io"
+	"math/big"
+
+	crand "crypto/rand"
+)
+
+// A Rand selects random elements of maps, drawing its randomness from a
+// caller-supplied source. A Rand is not safe for concurrent use unless its
+// source is.
+type Rand struct {
+	intn randIntn
+}
+
+// New returns a Rand that draws randomness from r. The Rand panics if r
+// returns an error, e.g. because it has been exhausted.
+//
+// Unlike the randmap package's Rand, identically-seeded Rands do not replay
+// the same selections: they choose among keys in the order returned by
+// reflect.Value.MapKeys, which varies from call to call.
+func New(r io.Reader) *Rand {
+	return &Rand{
+		intn: func(n int) int {
+			i, err := crand.Int(r, big.NewInt(int64(n)))
+			if err != nil {
+				panic("randmap: reading from Rand source: " + err.Error())
+			}
+			return int(i.Int64())
+		},
+	}
+}
+
+// Key returns a random key of m, which must be a non-empty map.
+func (r *Rand) Key(m interface{}) interface{} { return randKey(m, r.intn) }
+
+// Val returns a random value of m, which must be a non-empty map.
+func (r *Rand) Val(m interface{}) interface{} { return randVal(m, r.intn) }
+
+// Entry returns a random key of m along with its value. m must be a non-empty
+// map.
+func (r *Rand) Entry(m interface{}) (k, v interface{}) { return randKeyVal(m, r.intn) }
+
+// Iter returns a random iterator for m. Each call to Next will store the next
+// key/value pair in k and v, which must be pointers. The map must not be
+// modified during iteration; see Iterator.Next.
+func (r *Rand) Iter(m, k, v interface{}) *Iterator { return randIter(m, k, v, r.intn) }
+
+// TolerantIter returns a random iterator for m that permits the map to be
+// modified between calls to Next; see the TolerantIter function.
+func (r *Rand) TolerantIter(m, k, v interface{}) *Iterator {
+	return randTolerantIter(m, k, v, r.intn)
+}
+
+// SampleKeys returns n distinct random keys of m; see the SampleKeys
+// function.
+func (r *Rand) SampleKeys(m interface{}, n int) []interface{} {
+	return randSampleKeys(m, n, r.intn)
+}
+
+// SampleEntries returns n distinct random keys of m and their corresponding
+// values; see the SampleEntries function.
+func (r *Rand) SampleEntries(m interface{}, n int) (keys, vals []interface{}) {
+	return randSampleEntries(m, n, r.intn)
+}
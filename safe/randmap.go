@@ -5,10 +5,11 @@ import (
 	"reflect"
 
 	crand "crypto/rand"
-	mrand "math/rand"
 )
 
-// A randIntn function returns a random value in [0, n).
+// A randIntn function returns a uniform random value in [0, n). Reducing a
+// random word modulo n would favor small values, so implementations must use
+// rejection sampling, as crand.Int and the math/rand Intn functions do.
 type randIntn func(n int) int
 
 func cRandInt(n int) int {
@@ -16,10 +17,6 @@ func cRandInt(n int) int {
 	return int(i.Int64())
 }
 
-func mRandInt(n int) int {
-	return mrand.Intn(n)
-}
-
 func randKey(m interface{}, Intn randIntn) interface{} {
 	mv := reflect.ValueOf(m)
 	keys := mv.MapKeys()
@@ -33,6 +30,13 @@ func randVal(m interface{}, Intn randIntn) interface{} {
 	return val.Interface()
 }
 
+func randKeyVal(m interface{}, Intn randIntn) (k, v interface{}) {
+	mv := reflect.ValueOf(m)
+	keys := mv.MapKeys()
+	key := keys[Intn(len(keys))]
+	return key.Interface(), mv.MapIndex(key).Interface()
+}
+
 // An Iterator iterates over a map in random or pseudorandom order. It is
 // intended to be used in a for loop like so:
 //
@@ -46,24 +50,135 @@ func randVal(m interface{}, Intn randIntn) interface{} {
 type Iterator struct {
 	// map, stored so that we can lookup values via keys
 	m reflect.Value
-	// random permutation of map keys, resliced each time we iterate
+	// random permutation of map keys, and our position within it
 	perm []reflect.Value
+	pos  int
 	// settable Values for k and v
 	k, v reflect.Value
+	// used to reseed
+	intn randIntn
+	// whether the map may be modified during iteration; see TolerantIter
+	tolerant bool
+	// positions in perm of the entries removed by Delete, and whether the
+	// current entry can be deleted
+	deleted []int
+	cur     bool
 }
 
 // Next advances the Iterator to the next element in the map, storing its key
 // and value in the pointers passed during initialization. It returns false
 // when all of the elements have been enumerated.
+//
+// Next panics if the map has gained or lost entries since the Iterator was
+// created or last reseeded. To continue iterating after modifying the map,
+// call Reseed.
 func (i *Iterator) Next() bool {
-	if i == nil || len(i.perm) == 0 {
+	if i == nil {
 		return false
+	} else if !i.tolerant && i.pos < len(i.perm) && i.m.Len() != len(i.perm)-len(i.deleted) {
+		panic("randmap: map modified during iteration")
+	}
+	i.cur = false
+	for i.pos < len(i.perm) {
+		k := i.perm[i.pos]
+		i.pos++
+		v := i.m.MapIndex(k)
+		if !v.IsValid() && i.tolerant {
+			continue // deleted since the permutation was generated
+		}
+		i.k.Set(k)
+		i.v.Set(v)
+		i.cur = true
+		return true
+	}
+	return false
+}
+
+// Delete removes the current element, i.e. the one most recently stored by
+// Next, from the map. The rest of the iteration still visits every remaining
+// element exactly once. As with the builtin delete, an element whose key is
+// not equal to itself (such as NaN) is not removed. Delete panics if there is
+// no current element, or if it has already been deleted.
+func (i *Iterator) Delete() {
+	if i == nil || !i.cur {
+		panic("randmap: Delete called without a current element")
+	}
+	i.cur = false
+	if !i.tolerant && i.m.Len() != len(i.perm)-len(i.deleted) {
+		panic("randmap: map modified during iteration")
+	}
+	n := i.m.Len()
+	i.m.SetMapIndex(i.perm[i.pos-1], reflect.Value{})
+	if i.m.Len() != n {
+		i.deleted = append(i.deleted, i.pos-1)
+	}
+}
+
+// Reset restarts the Iterator, so that it enumerates the map's elements in
+// the same order again.
+func (i *Iterator) Reset() {
+	if i != nil {
+		i.pos = 0
+		i.cur = false
+		// drop the deleted keys, so that they aren't visited or counted
+		if len(i.deleted) > 0 {
+			perm := i.perm[:0]
+			for j, k := range i.perm {
+				if len(i.deleted) > 0 && i.deleted[0] == j {
+					i.deleted = i.deleted[1:]
+					continue
+				}
+				perm = append(perm, k)
+			}
+			i.perm, i.deleted = perm, nil
+		}
+	}
+}
+
+// Reseed restarts the Iterator with a fresh random order, drawn from the same
+// source of randomness as the original. Unlike Reset, Reseed may be called
+// after the map has been modified.
+func (i *Iterator) Reseed() {
+	if i != nil {
+		i.perm = shuffleKeys(i.m, i.intn)
+		i.pos = 0
+		i.deleted = nil
+		i.cur = false
+	}
+}
+
+// Skip advances the Iterator past the next n elements, without storing them.
+// It returns the number of elements skipped, which is less than n only if the
+// Iterator was exhausted.
+func (i *Iterator) Skip(n int) int {
+	if i == nil || n <= 0 {
+		return 0
+	}
+	i.cur = false
+	if rem := len(i.perm) - i.pos; n > rem {
+		n = rem
+	}
+	i.pos += n
+	return n
+}
+
+// Remaining returns the number of elements that the Iterator has not yet
+// enumerated.
+func (i *Iterator) Remaining() int {
+	if i == nil {
+		return 0
+	}
+	return len(i.perm) - i.pos
+}
+
+// shuffleKeys returns a random permutation of m's keys.
+func shuffleKeys(m reflect.Value, Intn randIntn) []reflect.Value {
+	keys := m.MapKeys()
+	for i := len(keys) - 1; i >= 1; i-- {
+		j := Intn(i + 1)
+		keys[i], keys[j] = keys[j], keys[i]
 	}
-	var k reflect.Value
-	k, i.perm = i.perm[0], i.perm[1:]
-	i.k.Set(k)
-	i.v.Set(i.m.MapIndex(k))
-	return true
+	return keys
 }
 
 func randIter(m, k, v interface{}, Intn randIntn) *Iterator {
@@ -78,19 +193,13 @@ func randIter(m, k, v interface{}, Intn randIntn) *Iterator {
 	kptr := reflect.ValueOf(k).Elem()
 	vptr := reflect.ValueOf(v).Elem()
 
-	// create a random permutation of m's keys
 	mv := reflect.ValueOf(m)
-	keys := mv.MapKeys()
-	for i := len(keys) - 1; i >= 1; i-- {
-		j := Intn(i + 1)
-		keys[i], keys[j] = keys[j], keys[i]
-	}
-
 	return &Iterator{
 		m:    mv,
-		perm: keys,
+		perm: shuffleKeys(mv, Intn),
 		k:    kptr,
 		v:    vptr,
+		intn: Intn,
 	}
 }
 
@@ -100,9 +209,13 @@ func Key(m interface{}) interface{} { return randKey(m, cRandInt) }
 // Val returns a uniform random value of m, which must be a non-empty map.
 func Val(m interface{}) interface{} { return randVal(m, cRandInt) }
 
+// Entry returns a uniform random key of m along with its value. m must be a
+// non-empty map.
+func Entry(m interface{}) (k, v interface{}) { return randKeyVal(m, cRandInt) }
+
 // Iter returns a random iterator for m. Each call to Next will store the next
-// key/value pair in k and v, which must be pointers. Modifying the map during
-// iteration will result in undefined behavior.
+// key/value pair in k and v, which must be pointers. The map must not be
+// modified during iteration; see Iterator.Next.
 func Iter(m, k, v interface{}) *Iterator { return randIter(m, k, v, cRandInt) }
 
 // FastKey returns a pseudorandom key of m, which must be a non-empty map.
@@ -111,7 +224,11 @@ func FastKey(m interface{}) interface{} { return randKey(m, mRandInt) }
 // FastVal returns a pseudorandom value of m, which must be a non-empty map.
 func FastVal(m interface{}) interface{} { return randVal(m, mRandInt) }
 
+// FastEntry returns a pseudorandom key of m along with its value. m must be a
+// non-empty map.
+func FastEntry(m interface{}) (k, v interface{}) { return randKeyVal(m, mRandInt) }
+
 // FastIter returns a pseudorandom iterator for m. Each call to Next will
-// store the next key/value pair in k and v, which must be pointers. Modifying
-// the map during iteration will result in undefined behavior.
+// store the next key/value pair in k and v, which must be pointers. The map
+// must not be modified during iteration; see Iterator.Next.
 func FastIter(m, k, v interface{}) *Iterator { return randIter(m, k, v, mRandInt) }
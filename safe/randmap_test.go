@@ -5,6 +5,8 @@ import (
 	"compress/gzip"
 	"math/rand"
 	"testing"
+
+	"github.com/lukechampine/randmap/internal/randtest"
 )
 
 // builtinSeekKey selects a key by advancing the map iterator a random number
@@ -129,6 +131,35 @@ func TestFastVal(t *testing.T) {
 	}
 }
 
+func TestEntry(t *testing.T) {
+	const iters = 100000
+	m := map[int]int{
+		0: 0,
+		1: 10,
+		2: 20,
+		3: 30,
+		4: 40,
+	}
+	for name, fn := range map[string]func(interface{}) (interface{}, interface{}){
+		"Entry":     Entry,
+		"FastEntry": FastEntry,
+	} {
+		counts := make([]int, len(m))
+		for i := 0; i < iters; i++ {
+			k, v := fn(m)
+			if v.(int) != m[k.(int)] {
+				t.Fatalf("%v returned mismatched entry %v: %v", name, k, v)
+			}
+			counts[k.(int)]++
+		}
+		for n, c := range counts {
+			if (iters/len(m))/2 > c || c > (iters/len(m))*2 {
+				t.Errorf("%v: suspicious count: expected %v-%v, got %v (%v)", name, (iters/len(m))/2, (iters/len(m))*2, c, n)
+			}
+		}
+	}
+}
+
 func TestEmpty(t *testing.T) {
 	defer func() {
 		if recover() == nil {
@@ -176,6 +207,26 @@ func BenchmarkKey(b *testing.B) {
 		}
 	})
 
+	// FastKey should scale with the number of goroutines; Key is limited by
+	// the throughput of the system's entropy source
+	b.Run("key-parallel", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = Key(m).(int)
+			}
+		})
+	})
+
+	b.Run("fastkey-parallel", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = FastKey(m).(int)
+			}
+		})
+	})
+
 	b.Run("seek", func(b *testing.B) {
 		b.ReportAllocs()
 		for i := 0; i < b.N; i++ {
@@ -265,6 +316,14 @@ func TestFastIter(t *testing.T) {
 	}
 }
 
+func fastIter(m, k, v interface{}) randtest.Iterator { return FastIter(m, k, v) }
+
+func TestIterReset(t *testing.T) { randtest.IterReset(t, fastIter, 100) }
+
+func TestIterModified(t *testing.T) { randtest.IterModified(t, fastIter, 100) }
+
+func TestIterDelete(t *testing.T) { randtest.IterDelete(t, fastIter, 100) }
+
 func TestIterBadType(t *testing.T) {
 	defer func() {
 		if recover() == nil {
@@ -0,0 +1,59 @@
+package randmap
+
+import "reflect"
+
+// randSample returns n distinct keys of m, selected with a partial
+// Fisher-Yates shuffle.
+func randSample(m interface{}, n int, Intn randIntn) []reflect.Value {
+	keys := reflect.ValueOf(m).MapKeys()
+	if n > len(keys) {
+		n = len(keys)
+	} else if n <= 0 {
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		j := i + Intn(len(keys)-i)
+		keys[i], keys[j] = keys[j], keys[i]
+	}
+	return keys[:n]
+}
+
+func randSampleKeys(m interface{}, n int, Intn randIntn) []interface{} {
+	var keys []interface{}
+	for _, k := range randSample(m, n, Intn) {
+		keys = append(keys, k.Interface())
+	}
+	return keys
+}
+
+func randSampleEntries(m interface{}, n int, Intn randIntn) (keys, vals []interface{}) {
+	mv := reflect.ValueOf(m)
+	for _, k := range randSample(m, n, Intn) {
+		keys = append(keys, k.Interface())
+		vals = append(vals, mv.MapIndex(k).Interface())
+	}
+	return keys, vals
+}
+
+// SampleKeys returns n distinct keys of m, in random order. Every n-subset of
+// m's keys is equally likely to be selected. If m has fewer than n entries,
+// SampleKeys returns all of its keys.
+func SampleKeys(m interface{}, n int) []interface{} { return randSampleKeys(m, n, cRandInt) }
+
+// SampleEntries returns n distinct keys of m and their corresponding values,
+// in random order. Every n-subset of m's entries is equally likely to be
+// selected. If m has fewer than n entries, SampleEntries returns all of them.
+func SampleEntries(m interface{}, n int) (keys, vals []interface{}) {
+	return randSampleEntries(m, n, cRandInt)
+}
+
+// FastSampleKeys returns n distinct pseudorandom keys of m. If m has fewer
+// than n entries, FastSampleKeys returns all of its keys.
+func FastSampleKeys(m interface{}, n int) []interface{} { return randSampleKeys(m, n, mRandInt) }
+
+// FastSampleEntries returns n distinct pseudorandom keys of m and their
+// corresponding values. If m has fewer than n entries, FastSampleEntries
+// returns all of them.
+func FastSampleEntries(m interface{}, n int) (keys, vals []interface{}) {
+	return randSampleEntries(m, n, mRandInt)
+}
@@ -0,0 +1,49 @@
+package randmap
+
+import "testing"
+
+func TestSampleKeys(t *testing.T) {
+	m := make(map[int]int)
+	for i := 0; i < 1000; i++ {
+		m[i] = i * 10
+	}
+	for _, n := range []int{0, 1, 50, 1000, 2000} {
+		keys, vals := SampleEntries(m, n)
+		exp := n
+		if exp > len(m) {
+			exp = len(m)
+		}
+		if len(keys) != exp || len(vals) != exp {
+			t.Fatalf("expected %v entries, got %v", exp, len(keys))
+		}
+		seen := make(map[int]bool)
+		for i, k := range keys {
+			if seen[k.(int)] {
+				t.Fatalf("key %v was sampled twice", k)
+			} else if vals[i].(int) != m[k.(int)] {
+				t.Fatalf("mismatched entry %v: %v", k, vals[i])
+			}
+			seen[k.(int)] = true
+		}
+	}
+
+	// each 2-subset of a 4-element map should be equally likely
+	small := map[int]int{0: 0, 1: 1, 2: 2, 3: 3}
+	const iters = 60000
+	counts := make(map[int]int)
+	for i := 0; i < iters; i++ {
+		var mask int
+		for _, k := range FastSampleKeys(small, 2) {
+			mask |= 1 << uint(k.(int))
+		}
+		counts[mask]++
+	}
+	if len(counts) != 6 {
+		t.Fatal("expected 6 distinct subsets, got", len(counts))
+	}
+	for mask, c := range counts {
+		if (iters/6)/2 > c || c > (iters/6)*2 {
+			t.Errorf("suspicious count: expected %v-%v, got %v (%b)", (iters/6)/2, (iters/6)*2, c, mask)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+//go:build go1.23
+// +build go1.23
+
+package randmap
+
+import "iter"
+
+// randAll returns a sequence over the entries of m. Each time the sequence is
+// iterated, a fresh permutation is generated.
+func randAll[K comparable, V any](m map[K]V, Intn randIntn) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for i := randIterOf(m, Intn); i.Next(); {
+			if !yield(i.k, i.v) {
+				return
+			}
+		}
+	}
+}
+
+func randKeys[K comparable, V any](m map[K]V, Intn randIntn) iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for i := randIterOf(m, Intn); i.Next(); {
+			if !yield(i.k) {
+				return
+			}
+		}
+	}
+}
+
+func randValues[K comparable, V any](m map[K]V, Intn randIntn) iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for i := randIterOf(m, Intn); i.Next(); {
+			if !yield(i.v) {
+				return
+			}
+		}
+	}
+}
+
+// All returns an iterator over the key/value pairs of m in random order. The
+// iteration panics if the map gains or loses entries before it completes,
+// even if the loop body itself modifies the map; see Iterator.Next.
+func All[K comparable, V any](m map[K]V) iter.Seq2[K, V] { return randAll(m, cRandInt) }
+
+// Keys returns an iterator over the keys of m in random order. The iteration
+// panics if the map is modified before it completes; see All.
+func Keys[K comparable, V any](m map[K]V) iter.Seq[K] { return randKeys(m, cRandInt) }
+
+// Values returns an iterator over the values of m in random order. The
+// iteration panics if the map is modified before it completes; see All.
+func Values[K comparable, V any](m map[K]V) iter.Seq[V] { return randValues(m, cRandInt) }
+
+// FastAll returns an iterator over the key/value pairs of m in pseudorandom
+// order. The iteration panics if the map is modified before it completes; see
+// All.
+func FastAll[K comparable, V any](m map[K]V) iter.Seq2[K, V] { return randAll(m, mRandInt) }
+
+// FastKeys returns an iterator over the keys of m in pseudorandom order. The
+// iteration panics if the map is modified before it completes; see All.
+func FastKeys[K comparable, V any](m map[K]V) iter.Seq[K] { return randKeys(m, mRandInt) }
+
+// FastValues returns an iterator over the values of m in pseudorandom order.
+// The iteration panics if the map is modified before it completes; see All.
+func FastValues[K comparable, V any](m map[K]V) iter.Seq[V] { return randValues(m, mRandInt) }
@@ -0,0 +1,21 @@
+package randmap
+
+func randTolerantIter(m, k, v interface{}, Intn randIntn) *Iterator {
+	i := randIter(m, k, v, Intn)
+	i.tolerant = true
+	return i
+}
+
+// TolerantIter returns a random iterator for m, like Iter, except that the map
+// may be modified by the iterating goroutine between calls to Next. Every
+// entry that is present in the map for the whole iteration is yielded exactly
+// once. An entry that is deleted before Next reaches it is not yielded, and
+// an entry that is inserted during the iteration is never yielded. Entries
+// whose keys are not equal to themselves, such as NaN, cannot be looked up,
+// so they are skipped. Remaining is only an estimate when the map has been
+// modified.
+func TolerantIter(m, k, v interface{}) *Iterator { return randTolerantIter(m, k, v, cRandInt) }
+
+// FastTolerantIter returns a pseudorandom iterator for m that permits the map
+// to be modified between calls to Next; see TolerantIter.
+func FastTolerantIter(m, k, v interface{}) *Iterator { return randTolerantIter(m, k, v, mRandInt) }
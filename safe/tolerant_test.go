@@ -0,0 +1,39 @@
+package randmap
+
+import "testing"
+
+func TestTolerantIter(t *testing.T) {
+	const n = 1000
+	m := make(map[int]int)
+	for i := 0; i < n; i++ {
+		m[i] = i
+	}
+	// every fifth entry may be deleted during the iteration, and new entries
+	// are inserted
+	deleted := make(map[int]bool)
+	seen := make(map[int]bool)
+	var k, v int
+	it := FastTolerantIter(m, &k, &v)
+	for j := 0; it.Next(); j++ {
+		if seen[k] {
+			t.Fatalf("key %v yielded twice", k)
+		} else if deleted[k] {
+			t.Fatalf("deleted key %v was yielded", k)
+		} else if m[k] != v {
+			t.Fatalf("key %v has wrong value %v", k, v)
+		}
+		seen[k] = true
+		if d := 5 * j; d < n {
+			delete(m, d)
+			deleted[d] = true
+		}
+		if j < n {
+			m[-j-1] = 0
+		}
+	}
+	for i := 0; i < n; i++ {
+		if !seen[i] && !deleted[i] {
+			t.Fatalf("key %v was never yielded", i)
+		}
+	}
+}
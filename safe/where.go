@@ -0,0 +1,56 @@
+package randmap
+
+import "reflect"
+
+// randKeyWhere visits the keys of m in random order, returning the first one
+// for which pred returns true.
+func randKeyWhere(m interface{}, Intn randIntn, pred func(k reflect.Value) bool) (interface{}, bool) {
+	keys := reflect.ValueOf(m).MapKeys()
+	for i := range keys {
+		j := i + Intn(len(keys)-i)
+		keys[i], keys[j] = keys[j], keys[i]
+		if pred(keys[i]) {
+			return keys[i].Interface(), true
+		}
+	}
+	return nil, false
+}
+
+func randKeyExcept(m, exclude interface{}, Intn randIntn) (interface{}, bool) {
+	kt := reflect.TypeOf(m).Key()
+	if et := reflect.TypeOf(exclude); et == nil || et.Kind() != reflect.Map || et.Key() != kt {
+		panic("wrong type for exclude: expected map with key type " + kt.String())
+	}
+	ev := reflect.ValueOf(exclude)
+	return randKeyWhere(m, Intn, func(k reflect.Value) bool {
+		return !ev.MapIndex(k).IsValid()
+	})
+}
+
+// KeyWhere returns a random key of m for which pred returns true. Each such
+// key is equally likely to be selected. If there is no such key, KeyWhere
+// returns false. pred must not modify m.
+func KeyWhere(m interface{}, pred func(k interface{}) bool) (interface{}, bool) {
+	return randKeyWhere(m, cRandInt, func(k reflect.Value) bool { return pred(k.Interface()) })
+}
+
+// KeyExcept returns a random key of m that is not a key of exclude, which
+// must be a map (typically a set) with the same key type as m. Each such key
+// is equally likely to be selected. If there is no such key, KeyExcept
+// returns false.
+func KeyExcept(m, exclude interface{}) (interface{}, bool) {
+	return randKeyExcept(m, exclude, cRandInt)
+}
+
+// FastKeyWhere returns a pseudorandom key of m for which pred returns true,
+// or false if there is no such key. pred must not modify m.
+func FastKeyWhere(m interface{}, pred func(k interface{}) bool) (interface{}, bool) {
+	return randKeyWhere(m, mRandInt, func(k reflect.Value) bool { return pred(k.Interface()) })
+}
+
+// FastKeyExcept returns a pseudorandom key of m that is not a key of exclude,
+// which must be a map with the same key type as m, or false if there is no
+// such key.
+func FastKeyExcept(m, exclude interface{}) (interface{}, bool) {
+	return randKeyExcept(m, exclude, mRandInt)
+}
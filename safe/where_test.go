@@ -0,0 +1,36 @@
+package randmap
+
+import "testing"
+
+func TestKeyWhere(t *testing.T) {
+	m := make(map[int]int)
+	for i := 0; i < 100; i++ {
+		m[i] = i
+	}
+	const iters = 10000
+	counts := make([]int, 5)
+	for i := 0; i < iters; i++ {
+		k, ok := FastKeyWhere(m, func(k interface{}) bool { return k.(int)%20 == 0 })
+		if !ok || k.(int)%20 != 0 {
+			t.Fatal("bad key:", k, ok)
+		}
+		counts[k.(int)/20]++
+	}
+	for n, c := range counts {
+		if (iters/5)/2 > c || c > (iters/5)*2 {
+			t.Errorf("suspicious count: expected %v-%v, got %v (%v)", (iters/5)/2, (iters/5)*2, c, n*20)
+		}
+	}
+
+	exclude := map[int]bool{}
+	for i := 1; i < 100; i++ {
+		exclude[i] = true
+	}
+	if k, ok := KeyExcept(m, exclude); !ok || k != 0 {
+		t.Fatal("expected key 0, got", k, ok)
+	}
+	exclude[0] = true
+	if k, ok := FastKeyExcept(m, exclude); ok {
+		t.Fatal("expected no key, got", k)
+	}
+}
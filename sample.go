@@ -0,0 +1,137 @@
+//go:build !purego
+// +build !purego
+
+package randmap
+
+import (
+	"reflect"
+	"unsafe"
+
+	crand "crypto/rand"
+
+	safe "github.com/lukechampine/randmap/safe"
+)
+
+// sampleScanRatio determines how a sample of n entries is selected from a map
+// with count entries. If n*sampleScanRatio < count, randSample walks a random
+// permutation of the map's slots, stopping after n hits; each slot it visits
+// costs four hashes, but it visits only about n*size/count of them. Otherwise,
+// randSample scans every slot once and selects entries with the appropriate
+// probability, which is about 100 times cheaper per slot;
+// BenchmarkSampleStrategy puts the crossover near n = count/100.
+var sampleScanRatio uintptr = 100
+
+// randSample calls fn on n distinct entries of m, selected uniformly at random
+// and visited in random order. If m has fewer than n entries, fn is called on
+// all of them. randSample uses O(n) memory.
+func randSample(m interface{}, n int, read randReader, fn func(it *hiter)) {
+	ei := (*emptyInterface)(unsafe.Pointer(&m))
+	t := (*maptype)(ei.typ)
+	h := (*hmap)(ei.val)
+	if n <= 0 || h == nil || h.length() == 0 {
+		return
+	}
+	count := uintptr(h.length())
+	if uintptr(n) > count {
+		n = int(count)
+	}
+
+	if uintptr(n)*sampleScanRatio < count {
+		// the first n entries of a random permutation are a uniform sample
+		si := newSlotIter(m, read)
+		for i := 0; i < n && si.next(); i++ {
+			fn(&si.it)
+		}
+		return
+	}
+
+	// Select each entry with probability need/remaining, where need is the
+	// number of entries still to be selected and remaining is the number of
+	// entries not yet considered (Knuth's Algorithm S). This selects a
+	// uniform n-subset in a single pass.
+	s := newSlotSpace(t, h)
+	var it hiter
+	slots := make([]uintptr, 0, n)
+	need, remaining := uintptr(n), count
+	for r := uintptr(0); r < s.size() && need > 0 && remaining > 0; r++ {
+		if !s.access(t, h, &it, r) {
+			continue
+		}
+		if randIndex(read, remaining) < need {
+			slots = append(slots, r)
+			need--
+		}
+		remaining--
+	}
+	// the selected slots are in order, so shuffle them
+	for i := len(slots) - 1; i > 0; i-- {
+		j := randIndex(read, uintptr(i+1))
+		slots[i], slots[j] = slots[j], slots[i]
+	}
+	for _, r := range slots {
+		if !s.access(t, h, &it, r) {
+			continue
+		}
+		if checkMode {
+			checkSlot(reflect.ValueOf(m), &it, s, r)
+		}
+		fn(&it)
+	}
+}
+
+func randSampleKeys(m interface{}, n int, read randReader) []interface{} {
+	kt := reflect.TypeOf(m).Key()
+	var keys []interface{}
+	randSample(m, n, read, func(it *hiter) {
+		keys = append(keys, reflect.NewAt(kt, it.key).Elem().Interface())
+	})
+	return keys
+}
+
+func randSampleEntries(m interface{}, n int, read randReader) (keys, vals []interface{}) {
+	kt, vt := reflect.TypeOf(m).Key(), reflect.TypeOf(m).Elem()
+	randSample(m, n, read, func(it *hiter) {
+		keys = append(keys, reflect.NewAt(kt, it.key).Elem().Interface())
+		vals = append(vals, reflect.NewAt(vt, it.value).Elem().Interface())
+	})
+	return keys, vals
+}
+
+// SampleKeys returns n distinct keys of m, in random order. Every n-subset of
+// m's keys is equally likely to be selected. If m has fewer than n entries,
+// SampleKeys returns all of its keys.
+func SampleKeys(m interface{}, n int) []interface{} {
+	if probeErr != nil {
+		return safe.SampleKeys(m, n)
+	}
+	return randSampleKeys(m, n, crand.Read)
+}
+
+// SampleEntries returns n distinct keys of m and their corresponding values,
+// in random order. Every n-subset of m's entries is equally likely to be
+// selected. If m has fewer than n entries, SampleEntries returns all of them.
+func SampleEntries(m interface{}, n int) (keys, vals []interface{}) {
+	if probeErr != nil {
+		return safe.SampleEntries(m, n)
+	}
+	return randSampleEntries(m, n, crand.Read)
+}
+
+// FastSampleKeys returns n distinct pseudorandom keys of m. If m has fewer
+// than n entries, FastSampleKeys returns all of its keys.
+func FastSampleKeys(m interface{}, n int) []interface{} {
+	if probeErr != nil {
+		return safe.FastSampleKeys(m, n)
+	}
+	return randSampleKeys(m, n, fastRead)
+}
+
+// FastSampleEntries returns n distinct pseudorandom keys of m and their
+// corresponding values. If m has fewer than n entries, FastSampleEntries
+// returns all of them.
+func FastSampleEntries(m interface{}, n int) (keys, vals []interface{}) {
+	if probeErr != nil {
+		return safe.FastSampleEntries(m, n)
+	}
+	return randSampleEntries(m, n, fastRead)
+}
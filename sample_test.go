@@ -0,0 +1,110 @@
+//go:build !purego
+// +build !purego
+
+package randmap
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestSampleKeys(t *testing.T) {
+	m := make(map[int]int)
+	for i := 0; i < 10000; i++ {
+		m[i] = i * 10
+	}
+	for _, n := range []int{0, 1, 50, 5000, 10000, 20000} {
+		keys, vals := SampleEntries(m, n)
+		exp := n
+		if exp > len(m) {
+			exp = len(m)
+		}
+		if len(keys) != exp || len(vals) != exp {
+			t.Fatalf("expected %v entries, got %v", exp, len(keys))
+		}
+		seen := make(map[int]bool)
+		for i, k := range keys {
+			if seen[k.(int)] {
+				t.Fatalf("key %v was sampled twice", k)
+			} else if vals[i].(int) != m[k.(int)] {
+				t.Fatalf("mismatched entry %v: %v", k, vals[i])
+			}
+			seen[k.(int)] = true
+		}
+	}
+	if keys := FastSampleKeys(make(map[int]int), 10); len(keys) != 0 {
+		t.Fatal("expected no keys from empty map, got", keys)
+	}
+}
+
+func TestSampleUniform(t *testing.T) {
+	defer func(r uintptr) { sampleScanRatio = r }(sampleScanRatio)
+	m := map[int]int{0: 0, 1: 1, 2: 2, 3: 3, 4: 4}
+	for _, strategy := range []struct {
+		name  string
+		ratio uintptr
+	}{
+		{"permutation", 0},
+		{"scan", 1 << 20},
+	} {
+		sampleScanRatio = strategy.ratio
+		for _, n := range []int{1, 2, 4} {
+			// count each subset, identified by its bitmask, and each position
+			// of each key
+			subsets := make(map[int]int)
+			positions := make([]int, len(m)*n)
+			for i := 0; i < 20000; i++ {
+				var mask int
+				for j, k := range FastSampleKeys(m, n) {
+					mask |= 1 << uint(k.(int))
+					positions[k.(int)*n+j]++
+				}
+				subsets[mask]++
+			}
+			counts := make([]int, 0, len(subsets))
+			for _, c := range subsets {
+				counts = append(counts, c)
+			}
+			name := strategy.name + "/" + strconv.Itoa(n)
+			if exp := binomial(len(m), n); len(counts) != exp {
+				t.Errorf("%v: expected %v distinct subsets, got %v", name, exp, len(counts))
+			}
+			checkUniform(t, name+"/subsets", counts)
+			checkUniform(t, name+"/order", positions)
+		}
+	}
+}
+
+func binomial(n, k int) int {
+	r := 1
+	for i := 1; i <= k; i++ {
+		r = r * (n - k + i) / i
+	}
+	return r
+}
+
+// BenchmarkSampleStrategy compares the two strategies for sampling n entries
+// from a large map. sampleScanRatio is set near the crossover.
+func BenchmarkSampleStrategy(b *testing.B) {
+	defer func(r uintptr) { sampleScanRatio = r }(sampleScanRatio)
+	m := make(map[int]int)
+	for i := 0; i < 1000000; i++ {
+		m[i] = i
+	}
+	for _, n := range []int{10, 100, 1000, 10000, 100000} {
+		for _, strategy := range []struct {
+			name  string
+			ratio uintptr
+		}{
+			{"permutation", 0},
+			{"scan", 1 << 30},
+		} {
+			sampleScanRatio = strategy.ratio
+			b.Run(strategy.name+"/"+strconv.Itoa(n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					_ = FastSampleKeys(m, n)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,129 @@
+//go:build !purego
+// +build !purego
+
+package randmap
+
+import (
+	"reflect"
+	"unsafe"
+
+	crand "crypto/rand"
+
+	safe "github.com/lukechampine/randmap/safe"
+)
+
+// A Sampler repeatedly selects random elements of a single map. Computing the
+// slot space of a bucket-based map requires scanning every bucket; a Sampler
+// does this once, and afterwards only when it detects that the map has been
+// resized or has gained overflow buckets. Selections are therefore O(1) for
+// as long as the map is unchanged.
+//
+// The map may be modified between selections, but not during them. For
+// bucket-based maps with more than 2^16 buckets, the runtime only
+// approximately counts overflow buckets; after modifying such a map, call
+// Reset to guarantee that the Sampler remains uniform.
+type Sampler struct {
+	m    interface{}
+	idx  indexer
+	fast bool // only used if the runtime probe failed
+
+	t      *maptype
+	h      *hmap
+	layout layout
+	space  slotSpace
+}
+
+// refresh recomputes s.space if the map's layout has changed.
+func (s *Sampler) refresh() {
+	if s.h == nil || s.h.length() == 0 {
+		panic("empty map")
+	}
+	if l := s.h.layout(); l != s.layout {
+		s.layout = l
+		s.space = newSlotSpace(s.t, s.h)
+	}
+}
+
+// Reset forces the Sampler to recompute the slot space of its map.
+func (s *Sampler) Reset() {
+	if s.h != nil {
+		s.layout = s.h.layout()
+		s.space = newSlotSpace(s.t, s.h)
+	}
+}
+
+// entry moves 'it' to a random entry of the Sampler's map.
+func (s *Sampler) entry(it *hiter) {
+	s.refresh()
+	r := randSlot(s.t, s.h, it, s.space, s.idx)
+	if checkMode {
+		checkSlot(reflect.ValueOf(s.m), it, s.space, r)
+	}
+}
+
+// Key returns a random key of the Sampler's map, which must be non-empty.
+func (s *Sampler) Key() interface{} {
+	if probeErr != nil {
+		if s.fast {
+			return safe.FastKey(s.m)
+		}
+		return safe.Key(s.m)
+	}
+	var it hiter
+	s.entry(&it)
+	return reflect.NewAt(reflect.TypeOf(s.m).Key(), it.key).Elem().Interface()
+}
+
+// Val returns a random value of the Sampler's map, which must be non-empty.
+func (s *Sampler) Val() interface{} {
+	if probeErr != nil {
+		if s.fast {
+			return safe.FastVal(s.m)
+		}
+		return safe.Val(s.m)
+	}
+	var it hiter
+	s.entry(&it)
+	return reflect.NewAt(reflect.TypeOf(s.m).Elem(), it.value).Elem().Interface()
+}
+
+// Entry returns a random key of the Sampler's map along with its value. The
+// map must be non-empty.
+func (s *Sampler) Entry() (k, v interface{}) {
+	if probeErr != nil {
+		if s.fast {
+			return safe.FastEntry(s.m)
+		}
+		return safe.Entry(s.m)
+	}
+	var it hiter
+	s.entry(&it)
+	mt := reflect.TypeOf(s.m)
+	k = reflect.NewAt(mt.Key(), it.key).Elem().Interface()
+	v = reflect.NewAt(mt.Elem(), it.value).Elem().Interface()
+	return k, v
+}
+
+func newSampler(m interface{}, idx indexer, fast bool) *Sampler {
+	if reflect.TypeOf(m).Kind() != reflect.Map {
+		panic("randmap: NewSampler called on non-map type " + reflect.TypeOf(m).String())
+	}
+	s := &Sampler{
+		m:    m,
+		idx:  idx,
+		fast: fast,
+	}
+	if probeErr == nil {
+		ei := (*emptyInterface)(unsafe.Pointer(&m))
+		s.t = (*maptype)(ei.typ)
+		s.h = (*hmap)(ei.val)
+		s.Reset()
+	}
+	return s
+}
+
+// NewSampler returns a Sampler that selects uniform random elements of m.
+func NewSampler(m interface{}) *Sampler { return newSampler(m, randReader(crand.Read), false) }
+
+// NewFastSampler returns a Sampler that selects pseudorandom elements of m.
+func NewFastSampler(m interface{}) *Sampler { return newSampler(m, randReader(fastRead), true) }
@@ -0,0 +1,67 @@
+package randmap
+
+import "testing"
+
+func TestSampler(t *testing.T) {
+	const iters = 100000
+	m := map[int]int{
+		0: 0,
+		1: 1,
+		2: 2,
+		3: 3,
+		4: 4,
+	}
+	s := NewFastSampler(m)
+	counts := make([]int, len(m))
+	for i := 0; i < iters; i++ {
+		counts[s.Key().(int)]++
+	}
+	for n, c := range counts {
+		if (iters/len(m))/2 > c || c > (iters/len(m))*2 {
+			t.Errorf("suspicious count: expected %v-%v, got %v (%v)", (iters/len(m))/2, (iters/len(m))*2, c, n)
+		}
+	}
+
+	// grow the map; the Sampler should notice
+	for i := len(m); i < 1000; i++ {
+		m[i] = i
+	}
+	counts = make([]int, len(m))
+	for i := 0; i < 30*len(m); i++ {
+		k := s.Key().(int)
+		if v := s.Val().(int); v < 0 || v >= len(m) {
+			t.Fatal("bad value:", v)
+		}
+		counts[k]++
+		if k, v := s.Entry(); k != v {
+			t.Fatalf("mismatched entry %v: %v", k, v)
+		}
+	}
+	for n, c := range counts {
+		if c == 0 {
+			t.Errorf("key %v was never selected after growing the map", n)
+		}
+	}
+}
+
+func BenchmarkSampler(b *testing.B) {
+	m := make(map[int]int, 10000)
+	for i := 0; i < 10000; i++ {
+		m[i] = i
+	}
+
+	b.Run("fastkey", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = FastKey(m).(int)
+		}
+	})
+
+	b.Run("sampler", func(b *testing.B) {
+		b.ReportAllocs()
+		s := NewFastSampler(m)
+		for i := 0; i < b.N; i++ {
+			_ = s.Key().(int)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+//go:build go1.23 && !purego
+// +build go1.23,!purego
+
+package randmap
+
+import (
+	crand "crypto/rand"
+	"iter"
+
+	safe "github.com/lukechampine/randmap/safe"
+)
+
+// randAll returns a sequence over the entries of m. Each time the sequence is
+// iterated, a fresh permutation is generated.
+func randAll[K comparable, V any](m map[K]V, read randReader) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		si := newSlotIter(m, read)
+		if si == nil {
+			return
+		}
+		for si.next() {
+			if !yield(*(*K)(si.it.key), *(*V)(si.it.value)) {
+				return
+			}
+		}
+	}
+}
+
+func randKeys[K comparable, V any](m map[K]V, read randReader) iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for k := range randAll(m, read) {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+func randValues[K comparable, V any](m map[K]V, read randReader) iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, v := range randAll(m, read) {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// All returns an iterator over the key/value pairs of m in random order. The
+// iteration panics if the map gains or loses entries before it completes,
+// even if the loop body itself modifies the map; see Iterator.Next.
+func All[K comparable, V any](m map[K]V) iter.Seq2[K, V] {
+	if probeErr != nil {
+		return safe.All(m)
+	}
+	return randAll(m, crand.Read)
+}
+
+// Keys returns an iterator over the keys of m in random order. The iteration
+// panics if the map is modified before it completes; see All.
+func Keys[K comparable, V any](m map[K]V) iter.Seq[K] {
+	if probeErr != nil {
+		return safe.Keys(m)
+	}
+	return randKeys(m, crand.Read)
+}
+
+// Values returns an iterator over the values of m in random order. The
+// iteration panics if the map is modified before it completes; see All.
+func Values[K comparable, V any](m map[K]V) iter.Seq[V] {
+	if probeErr != nil {
+		return safe.Values(m)
+	}
+	return randValues(m, crand.Read)
+}
+
+// FastAll returns an iterator over the key/value pairs of m in pseudorandom
+// order. The iteration panics if the map is modified before it completes; see
+// All.
+func FastAll[K comparable, V any](m map[K]V) iter.Seq2[K, V] {
+	if probeErr != nil {
+		return safe.FastAll(m)
+	}
+	return randAll(m, fastRead)
+}
+
+// FastKeys returns an iterator over the keys of m in pseudorandom order. The
+// iteration panics if the map is modified before it completes; see All.
+func FastKeys[K comparable, V any](m map[K]V) iter.Seq[K] {
+	if probeErr != nil {
+		return safe.FastKeys(m)
+	}
+	return randKeys(m, fastRead)
+}
+
+// FastValues returns an iterator over the values of m in pseudorandom order.
+// The iteration panics if the map is modified before it completes; see All.
+func FastValues[K comparable, V any](m map[K]V) iter.Seq[V] {
+	if probeErr != nil {
+		return safe.FastValues(m)
+	}
+	return randValues(m, fastRead)
+}
@@ -0,0 +1,24 @@
+//go:build go1.23
+// +build go1.23
+
+package randmap
+
+import (
+	"testing"
+
+	"github.com/lukechampine/randmap/internal/randtest"
+)
+
+func TestAll(t *testing.T) {
+	randtest.All(t, All[int, int])
+	randtest.All(t, FastAll[int, int])
+}
+
+func TestKeysValues(t *testing.T) {
+	randtest.KeysValues(t, Keys[int, int], FastValues[int, int])
+	randtest.KeysValues(t, FastKeys[int, int], Values[int, int])
+}
+
+func TestAllBreak(t *testing.T) { randtest.AllBreak(t, FastKeys[int, int]) }
+
+func TestAllModified(t *testing.T) { randtest.AllModified(t, FastAll[int, int]) }
@@ -0,0 +1,100 @@
+//go:build !purego
+// +build !purego
+
+package randmap
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+// smallIter is the largest map (by number of slots) for which Iter shuffles
+// the map's occupied slots up front, instead of walking a Feistel permutation
+// of the whole slot space. The Feistel generator computes four hashes per
+// slot, occupied or not, whereas a shuffle only draws four random bytes per
+// entry, so BenchmarkIterStrategy shows the shuffle winning at every size.
+// The threshold is therefore not a crossover point: it caps the memory held
+// by an Iterator, which keeps its shuffled slots (4 bytes per entry) for its
+// whole lifetime, at 16 KiB, so that Iter stays in constant space for large
+// maps.
+var smallIter uintptr = 4096
+
+// randPool holds scratch buffers for the random bytes consumed by a shuffle.
+// The shuffled slots themselves can't be pooled, since the Iterator keeps
+// them until it is garbage collected.
+var randPool = sync.Pool{
+	New: func() interface{} { return new([]byte) },
+}
+
+// A shuffleGenerator yields the occupied slots of a map in an order
+// determined up front by a Fisher-Yates shuffle.
+type shuffleGenerator struct {
+	slots []uint32
+	i     int
+}
+
+func (g *shuffleGenerator) Next() (uint32, bool) {
+	if g.i == len(g.slots) {
+		return 0, false
+	}
+	r := g.slots[g.i]
+	g.i++
+	return r, true
+}
+
+// Reset restarts the shuffled order from the beginning.
+func (g *shuffleGenerator) Reset() { g.i = 0 }
+
+// MarshalBinary implements encoding.BinaryMarshaler. The encoding is the
+// position of the generator, followed by the shuffled slots.
+func (g *shuffleGenerator) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 4+4*len(g.slots))
+	binary.LittleEndian.PutUint32(b, uint32(g.i))
+	for i, r := range g.slots {
+		binary.LittleEndian.PutUint32(b[4+4*i:], r)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (g *shuffleGenerator) UnmarshalBinary(b []byte) error {
+	if len(b) < 4 || len(b)%4 != 0 {
+		return errors.New("randmap: invalid shuffle encoding length")
+	}
+	slots := make([]uint32, len(b)/4-1)
+	for i := range slots {
+		slots[i] = binary.LittleEndian.Uint32(b[4+4*i:])
+	}
+	pos := int(binary.LittleEndian.Uint32(b))
+	if pos > len(slots) {
+		return errors.New("randmap: invalid shuffle position")
+	}
+	*g = shuffleGenerator{slots: slots, i: pos}
+	return nil
+}
+
+func newShuffleGenerator(t *maptype, h *hmap, s slotSpace, read randReader) *shuffleGenerator {
+	slots := make([]uint32, 0, h.length())
+	var it hiter
+	for r := uintptr(0); r < s.size(); r++ {
+		if s.access(t, h, &it, r) {
+			slots = append(slots, uint32(r))
+		}
+	}
+
+	// draw all of the randomness we need at once
+	n := len(slots)
+	buf := randPool.Get().(*[]byte)
+	if cap(*buf) < 4*n {
+		*buf = make([]byte, 4*n)
+	}
+	rand := (*buf)[:4*n]
+	read(rand)
+	for i := n - 1; i >= 1; i-- {
+		j := reduce32(binary.LittleEndian.Uint32(rand[4*i:]), uint32(i+1), read)
+		slots[i], slots[j] = slots[j], slots[i]
+	}
+	randPool.Put(buf)
+	return &shuffleGenerator{slots: slots}
+}
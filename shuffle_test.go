@@ -0,0 +1,71 @@
+//go:build !purego
+// +build !purego
+
+package randmap
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestShuffleIter(t *testing.T) {
+	// maps on either side of the threshold should be fully enumerated
+	for _, n := range []int{1, 7, 100, 1000} {
+		m := make(map[int]int)
+		for i := 0; i < n; i++ {
+			m[i] = i
+		}
+		for j := 0; j < 10; j++ {
+			seen := make([]bool, n)
+			var k, v int
+			it := FastIter(m, &k, &v)
+			for it.Next() {
+				if seen[k] {
+					t.Fatalf("%v visited twice", k)
+				}
+				seen[k] = true
+			}
+			if it.Next() {
+				t.Fatal("Next returned true after iteration finished")
+			}
+			for k, ok := range seen {
+				if !ok {
+					t.Fatalf("%v never visited (map of %v)", k, n)
+				}
+			}
+		}
+	}
+}
+
+// BenchmarkIterStrategy compares the two strategies for iterating over maps
+// of various sizes.
+func BenchmarkIterStrategy(b *testing.B) {
+	defer func(n uintptr) { smallIter = n }(smallIter)
+	// sizes past smallIter show that it is not a crossover point
+	for _, n := range []int{8, 64, 512, 4096, 32768, 262144} {
+		m := make(map[int]int, n)
+		for i := 0; i < n; i++ {
+			m[i] = i
+		}
+		b.Run("feistel/"+strconv.Itoa(n), func(b *testing.B) {
+			smallIter = 0
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var k, v int
+				it := FastIter(m, &k, &v)
+				for it.Next() {
+				}
+			}
+		})
+		b.Run("shuffle/"+strconv.Itoa(n), func(b *testing.B) {
+			smallIter = ^uintptr(0)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var k, v int
+				it := FastIter(m, &k, &v)
+				for it.Next() {
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+//go:build !purego
+// +build !purego
+
+package randmap
+
+import (
+	"sort"
+	"unsafe"
+
+	crand "crypto/rand"
+
+	safe "github.com/lukechampine/randmap/safe"
+)
+
+// hashes implements sort.Interface.
+type hashes []uint64
+
+func (h hashes) Len() int           { return len(h) }
+func (h hashes) Less(i, j int) bool { return h[i] < h[j] }
+func (h hashes) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+// keyHash returns a 64-bit hash of the key k, using the map's own hash
+// function and seed.
+func (si *slotIter) keyHash(k unsafe.Pointer) uint64 {
+	seed := si.h.hashSeed()
+	x := uint64(si.t.hashKey(k, seed))
+	if ptrSize == 4 {
+		// widen the hash, so that collisions remain unlikely in large maps
+		x = x<<32 | uint64(si.t.hashKey(k, ^seed))
+	}
+	return x
+}
+
+// firstVisit reports whether the entry that si.it points to was not yielded
+// before the permutation was last regenerated, and records it as yielded.
+// Entries yielded since then need not be checked, since the current
+// permutation visits each slot at most once.
+func (si *slotIter) firstVisit() bool {
+	x := si.keyHash(si.it.key)
+	seen := si.yielded[:si.sorted]
+	if i := sort.Search(len(seen), func(i int) bool { return seen[i] >= x }); i < len(seen) && seen[i] == x {
+		return false
+	}
+	si.yielded = append(si.yielded, x)
+	return true
+}
+
+// regenerate creates a fresh permutation of the map's current slot space,
+// after some of the map's entries have moved to other slots. The entries
+// already yielded are skipped by firstVisit.
+func (si *slotIter) regenerate() {
+	sort.Sort(hashes(si.yielded))
+	si.sorted = len(si.yielded)
+	si.permute()
+}
+
+// track makes si tolerate entries moving to other slots. It must be called
+// while si.it points to an entry that was just yielded. Since the map has not
+// been modified, the entries yielded so far are the ones in the slots visited
+// up to and including si.slot, so they can be recovered by replaying the
+// permutation.
+func (si *slotIter) track() {
+	si.gen.Reset()
+	var it hiter
+	for {
+		r, ok := si.gen.Next()
+		if !ok {
+			break
+		} else if uintptr(r) < si.space.size() && si.space.access(si.t, si.h, &it, uintptr(r)) {
+			si.yielded = append(si.yielded, si.keyHash(it.key))
+		}
+		if r == si.slot {
+			break
+		}
+	}
+	si.place = newPlacement(si.t, si.h)
+	si.tracking = true
+}
+
+func randTolerantIter(m, k, v interface{}, read randReader) *Iterator {
+	i := randIter(m, k, v, read)
+	if i == nil {
+		return nil
+	}
+	i.si.tolerant = true
+	i.si.tracking = true
+	i.si.place = newPlacement(i.si.t, i.si.h)
+	// the map may gain and lose entries, so the iteration can't be checked
+	// for completeness
+	i.si.check = nil
+	return i
+}
+
+// TolerantIter returns a random iterator for m, like Iter, except that the map
+// may be modified by the iterating goroutine between calls to Next. In
+// particular, the map may grow. Whenever its entries move to new slots, the
+// Iterator generates a fresh permutation of the map, skipping the entries it
+// has already yielded. To recognize them, it records a 64-bit hash of each
+// key that it yields.
+//
+// Every entry that is present in the map for the whole iteration is yielded
+// exactly once. An entry that is deleted before Next reaches it is not
+// yielded, and an entry that is inserted during the iteration may or may not
+// be yielded. Keys that are not equal to themselves, such as NaN, are an
+// exception: since their hashes vary, they may be yielded more than once if
+// the map grows. Remaining is only an estimate when the map has been
+// modified. The map must still not be written concurrently with Next, and
+// the Iterator cannot be checkpointed.
+func TolerantIter(m, k, v interface{}) *Iterator {
+	if probeErr != nil {
+		return &Iterator{fallback: safe.TolerantIter(m, k, v)}
+	}
+	return randTolerantIter(m, k, v, crand.Read)
+}
+
+// FastTolerantIter returns a pseudorandom iterator for m that permits the map
+// to be modified between calls to Next; see TolerantIter.
+func FastTolerantIter(m, k, v interface{}) *Iterator {
+	if probeErr != nil {
+		return &Iterator{fallback: safe.FastTolerantIter(m, k, v)}
+	}
+	return randTolerantIter(m, k, v, fastRead)
+}
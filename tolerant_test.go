@@ -0,0 +1,57 @@
+package randmap
+
+import (
+	"testing"
+
+	"github.com/lukechampine/randmap/internal/randtest"
+)
+
+func TestTolerantIter(t *testing.T) {
+	for _, n := range iterSizes {
+		m := randtest.IntMap(n)
+		// every fifth entry may be deleted during the iteration; the map
+		// also grows to four times its original size, moving every entry
+		// several times
+		deleted := make(map[int]bool)
+		seen := make(map[int]bool)
+		var k, v int
+		it := FastTolerantIter(m, &k, &v)
+		for j := 0; it.Next(); j++ {
+			if seen[k] {
+				t.Fatalf("%v: key %v yielded twice", n, k)
+			} else if deleted[k] {
+				t.Fatalf("%v: deleted key %v was yielded", n, k)
+			} else if m[k] != v {
+				t.Fatalf("%v: key %v has wrong value %v", n, k, v)
+			}
+			seen[k] = true
+			if d := 5 * j; d < n {
+				delete(m, d)
+				deleted[d] = true
+			}
+			// new entries may be yielded, so stop inserting eventually
+			for i := 0; i < 3 && j < n; i++ {
+				m[-(3*j + i + 1)] = 0
+			}
+		}
+		for i := 0; i < n; i++ {
+			if !seen[i] && !deleted[i] {
+				t.Fatalf("%v: key %v was never yielded", n, i)
+			}
+		}
+	}
+
+	// an empty map is not an error
+	var k, v int
+	if FastTolerantIter(make(map[int]int), &k, &v).Next() {
+		t.Fatal("expected empty map to yield nothing")
+	}
+}
+
+func TestTolerantIterCheckpoint(t *testing.T) {
+	m := map[int]int{0: 0, 1: 1, 2: 2}
+	var k, v int
+	if _, err := TolerantIter(m, &k, &v).MarshalBinary(); err == nil {
+		t.Fatal("expected tolerant Iterator not to be checkpointed")
+	}
+}
@@ -0,0 +1,115 @@
+//go:build !purego
+// +build !purego
+
+package randmap
+
+import (
+	"reflect"
+	"unsafe"
+
+	crand "crypto/rand"
+
+	safe "github.com/lukechampine/randmap/safe"
+)
+
+// randEntryWhere returns a hiter pointing to a uniform random entry of m for
+// which pred returns true, or false if there is no such entry.
+//
+// Like randSlot, it first probes random slots, accepting an occupied slot
+// only if pred holds; this selects a uniform matching entry, but it may never
+// find one. So if maxProbes probes miss, it walks a random permutation of the
+// slots and returns the first matching entry, which is also uniform. The walk
+// visits every slot, so it terminates even if no entry matches.
+func randEntryWhere(m interface{}, read randReader, pred func(it *hiter) bool) (hiter, bool) {
+	ei := (*emptyInterface)(unsafe.Pointer(&m))
+	t := (*maptype)(ei.typ)
+	h := (*hmap)(ei.val)
+	if h == nil || h.length() == 0 {
+		return hiter{}, false
+	}
+	s := newSlotSpace(t, h)
+	var it hiter
+	if count, size := uintptr(h.length()), s.size(); count*sparseRatio >= size {
+		for i := 0; i < maxProbes; i++ {
+			r := randIndex(read, size)
+			if s.access(t, h, &it, r) && pred(&it) {
+				if checkMode {
+					checkSlot(reflect.ValueOf(m), &it, s, r)
+				}
+				return it, true
+			}
+		}
+	}
+	for si := newSlotIter(m, read); si.next(); {
+		if pred(&si.it) {
+			return si.it, true
+		}
+	}
+	return hiter{}, false
+}
+
+func randKeyWhere(m interface{}, read randReader, pred func(k interface{}) bool) (interface{}, bool) {
+	kt := reflect.TypeOf(m).Key()
+	it, ok := randEntryWhere(m, read, func(it *hiter) bool {
+		return pred(reflect.NewAt(kt, it.key).Elem().Interface())
+	})
+	if !ok {
+		return nil, false
+	}
+	return reflect.NewAt(kt, it.key).Elem().Interface(), true
+}
+
+func randKeyExcept(m, exclude interface{}, read randReader) (interface{}, bool) {
+	kt := reflect.TypeOf(m).Key()
+	if et := reflect.TypeOf(exclude); et == nil || et.Kind() != reflect.Map || et.Key() != kt {
+		panic("wrong type for exclude: expected map with key type " + kt.String())
+	}
+	ev := reflect.ValueOf(exclude)
+	it, ok := randEntryWhere(m, read, func(it *hiter) bool {
+		return !ev.MapIndex(reflect.NewAt(kt, it.key).Elem()).IsValid()
+	})
+	if !ok {
+		return nil, false
+	}
+	return reflect.NewAt(kt, it.key).Elem().Interface(), true
+}
+
+// KeyWhere returns a random key of m for which pred returns true. Each such
+// key is equally likely to be selected. If there is no such key, KeyWhere
+// returns false. pred must not modify m.
+func KeyWhere(m interface{}, pred func(k interface{}) bool) (interface{}, bool) {
+	if probeErr != nil {
+		return safe.KeyWhere(m, pred)
+	}
+	return randKeyWhere(m, crand.Read, pred)
+}
+
+// KeyExcept returns a random key of m that is not a key of exclude, which
+// must be a map (typically a set) with the same key type as m. Each such key
+// is equally likely to be selected. If there is no such key, KeyExcept
+// returns false.
+func KeyExcept(m, exclude interface{}) (interface{}, bool) {
+	if probeErr != nil {
+		return safe.KeyExcept(m, exclude)
+	}
+	return randKeyExcept(m, exclude, crand.Read)
+}
+
+// FastKeyWhere returns a pseudorandom key of m for which pred returns true,
+// or false if there is no such key. pred must not modify m.
+func FastKeyWhere(m interface{}, pred func(k interface{}) bool) (interface{}, bool) {
+	if probeErr != nil {
+		return safe.FastKeyWhere(m, pred)
+	}
+	return randKeyWhere(m, fastRead, pred)
+}
+
+// FastKeyExcept returns a pseudorandom key of m that is not a key of exclude,
+// which must be a map with the same key type as m, or false if there is no
+// such key.
+func FastKeyExcept(m, exclude interface{}) (interface{}, bool) {
+	if probeErr != nil {
+		return safe.FastKeyExcept(m, exclude)
+	}
+	return randKeyExcept(m, exclude, fastRead)
+}
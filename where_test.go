@@ -0,0 +1,61 @@
+package randmap
+
+import "testing"
+
+func TestKeyWhere(t *testing.T) {
+	m := make(map[int]int)
+	for i := 0; i < 100; i++ {
+		m[i] = i
+	}
+
+	// the result should be uniform among the matching keys
+	exclude := make(map[int]struct{})
+	for i := 0; i < 95; i++ {
+		exclude[i] = struct{}{}
+	}
+	counts := make([]int, 5)
+	for i := 0; i < 5000; i++ {
+		k, ok := FastKeyExcept(m, exclude)
+		if !ok || k.(int) < 95 {
+			t.Fatal("bad key:", k, ok)
+		}
+		counts[k.(int)-95]++
+	}
+	checkUniform(t, "FastKeyExcept", counts)
+	counts = make([]int, 5)
+	for i := 0; i < 5000; i++ {
+		k, ok := KeyWhere(m, func(k interface{}) bool { return k.(int)%20 == 0 })
+		if !ok || k.(int)%20 != 0 {
+			t.Fatal("bad key:", k, ok)
+		}
+		counts[k.(int)/20]++
+	}
+	checkUniform(t, "KeyWhere", counts)
+
+	// a single match in a large map is unlikely to be found by probing
+	large := make(map[int]int)
+	for i := 0; i < 100000; i++ {
+		large[i] = i
+	}
+	if k, ok := FastKeyWhere(large, func(k interface{}) bool { return k.(int) == 12345 }); !ok || k.(int) != 12345 {
+		t.Fatal("failed to find only matching key:", k, ok)
+	}
+
+	// no matches
+	if k, ok := KeyExcept(m, m); ok {
+		t.Fatal("expected no key, got", k)
+	}
+	if k, ok := FastKeyWhere(make(map[int]int), func(interface{}) bool { return true }); ok {
+		t.Fatal("expected no key from empty map, got", k)
+	}
+	if _, ok := KeyExcept(m, map[int]bool(nil)); !ok {
+		t.Fatal("expected a key when exclude is nil")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic for mismatched exclude type")
+		}
+	}()
+	KeyExcept(m, map[string]bool{})
+}